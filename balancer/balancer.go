@@ -0,0 +1,161 @@
+package main
+
+import (
+	"container/heap"
+	"context"
+)
+
+// Request is a unit of work: Fn is run by a worker and its result is
+// sent on C.
+type Request[R any] struct {
+	Fn func() R
+	C  chan R
+}
+
+// WorkerStats is a snapshot of one worker's load.
+type WorkerStats struct {
+	ID        int
+	Pending   int // requests queued or running
+	Completed int
+}
+
+// Balancer hands each request to the least loaded worker, the load
+// balancer from Rob Pike's "Concurrency is not Parallelism". Workers
+// are kept in a heap ordered by pending requests; a worker reports on
+// done when it finishes one, and the balancer moves it back down.
+type Balancer[R any] struct {
+	pool  pool[R]
+	done  chan *worker[R]
+	stats chan chan []WorkerStats
+	ended chan struct{} // closed when Balance returns
+}
+
+type worker[R any] struct {
+	id        int
+	requests  chan Request[R]
+	pending   int
+	completed int
+	index     int // in the heap
+}
+
+// New returns a Balancer over workers goroutines, each queueing up
+// to buffer requests. They start with Balance.
+func New[R any](workers, buffer int) *Balancer[R] {
+	b := &Balancer[R]{
+		done:  make(chan *worker[R], max(workers, 1)*max(buffer, 1)),
+		stats: make(chan chan []WorkerStats),
+		ended: make(chan struct{}),
+	}
+	for i := range max(workers, 1) {
+		w := &worker[R]{id: i, requests: make(chan Request[R], max(buffer, 1)), index: i}
+		b.pool = append(b.pool, w)
+	}
+	heap.Init(&b.pool)
+	return b
+}
+
+// Balance dispatches requests from work until work is closed or ctx is
+// done, then waits for the requests already handed out and stops the
+// workers. Call it once.
+func (b *Balancer[R]) Balance(ctx context.Context, work <-chan Request[R]) {
+	defer close(b.ended)
+	for _, w := range b.pool {
+		go w.work(b.done)
+	}
+
+	inFlight := 0
+	done := ctx.Done() // nil once seen, or it would be ready forever
+	for work != nil || inFlight > 0 {
+		// Only take work when the lightest worker has room in its queue,
+		// so dispatch never blocks the loop.
+		in := work
+		if len(b.pool[0].requests) == cap(b.pool[0].requests) {
+			in = nil
+		}
+		select {
+		case req, ok := <-in:
+			if !ok {
+				work = nil
+				break
+			}
+			b.dispatch(req)
+			inFlight++
+		case w := <-b.done:
+			b.completed(w)
+			inFlight--
+		case c := <-b.stats:
+			c <- b.snapshot()
+		case <-done:
+			work, done = nil, nil
+		}
+	}
+
+	for _, w := range b.pool {
+		close(w.requests)
+	}
+}
+
+// Stats returns the current load of every worker, by ID, or nil once
+// Balance has returned. It blocks until Balance is running.
+func (b *Balancer[R]) Stats() []WorkerStats {
+	c := make(chan []WorkerStats)
+	select {
+	case b.stats <- c:
+		return <-c
+	case <-b.ended:
+		return nil
+	}
+}
+
+func (b *Balancer[R]) dispatch(req Request[R]) {
+	w := heap.Pop(&b.pool).(*worker[R])
+	w.requests <- req
+	w.pending++
+	heap.Push(&b.pool, w)
+}
+
+func (b *Balancer[R]) completed(w *worker[R]) {
+	w.pending--
+	w.completed++
+	heap.Fix(&b.pool, w.index)
+}
+
+func (b *Balancer[R]) snapshot() []WorkerStats {
+	stats := make([]WorkerStats, len(b.pool))
+	for _, w := range b.pool {
+		stats[w.id] = WorkerStats{ID: w.id, Pending: w.pending, Completed: w.completed}
+	}
+	return stats
+}
+
+func (w *worker[R]) work(done chan<- *worker[R]) {
+	for req := range w.requests {
+		req.C <- req.Fn()
+		done <- w
+	}
+}
+
+// pool is a heap of workers, least pending first.
+type pool[R any] []*worker[R]
+
+func (p pool[R]) Len() int           { return len(p) }
+func (p pool[R]) Less(i, j int) bool { return p[i].pending < p[j].pending }
+
+func (p pool[R]) Swap(i, j int) {
+	p[i], p[j] = p[j], p[i]
+	p[i].index = i
+	p[j].index = j
+}
+
+func (p *pool[R]) Push(x any) {
+	w := x.(*worker[R])
+	w.index = len(*p)
+	*p = append(*p, w)
+}
+
+func (p *pool[R]) Pop() any {
+	old := *p
+	w := old[len(old)-1]
+	*p = old[:len(old)-1]
+	return w
+}
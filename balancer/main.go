@@ -0,0 +1,57 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"time"
+)
+
+func main() {
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	b := New[int](8, 4)
+	work := make(chan Request[int])
+	for i := 0; i < 20; i++ {
+		go requester(ctx, work)
+	}
+	go b.Balance(ctx, work) // stops with ctx, work is never closed
+
+	// Print the load spread while the requesters run
+	for {
+		select {
+		case <-time.After(500 * time.Millisecond):
+			for _, s := range b.Stats() {
+				fmt.Printf("%2d/%-4d ", s.Pending, s.Completed)
+			}
+			fmt.Println()
+		case <-ctx.Done():
+			fmt.Println("End of main")
+			return
+		}
+	}
+}
+
+// requester sends a request now and then and waits for its result.
+func requester(ctx context.Context, work chan<- Request[int]) {
+	c := make(chan int, 1)
+	for {
+		select {
+		case <-time.After(time.Duration(rand.Intn(200)) * time.Millisecond):
+		case <-ctx.Done():
+			return
+		}
+		n := rand.Intn(100)
+		select {
+		case work <- Request[int]{Fn: func() int {
+			time.Sleep(time.Duration(n) * time.Millisecond)
+			return n * n
+		}, C: c}:
+		case <-ctx.Done():
+			return
+		}
+		<-c
+	}
+}
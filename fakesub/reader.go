@@ -1,6 +1,7 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"time"
 )
@@ -24,6 +25,13 @@ type Subscription interface {
 }
 
 func main() {
+	seed := flag.Int64("seed", 0, "seed for the fake feeds' delays, random if 0")
+	flag.Parse()
+	if *seed == 0 {
+		*seed = time.Now().UnixNano()
+	}
+	FakeSeed = *seed
+	fmt.Println("Seed:", FakeSeed) // rerun with -seed to reproduce
 
 	// Subscribe to some feeds and create a merged update stream
 	merged := Merge(
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"errors"
+)
+
+// An actor is a goroutine that owns some state and changes it only in
+// response to commands from its mailbox, the way sub.loop owns pending.
+// Nothing else touches the state, so nothing needs a lock. A Mailbox
+// replaces the request channels such loops would otherwise declare one
+// by one, like closing chan chan error: each request travels in an
+// Envelope that carries its own reply channel.
+
+// ErrStopped is returned by Ask once the actor has stopped.
+var ErrStopped = errors.New("actor: stopped")
+
+// Mailbox carries commands to an actor whose state is an S.
+type Mailbox[S any] struct {
+	inbox   chan Envelope[S]
+	stopped chan struct{}
+}
+
+// Envelope holds one request, ready to be run against the
+// actor's state.
+type Envelope[S any] struct {
+	run func(*S)
+}
+
+// Run carries out the request. The actor calls it from its loop.
+func (e Envelope[S]) Run(state *S) {
+	e.run(state)
+}
+
+// NewMailbox returns an empty mailbox.
+func NewMailbox[S any]() *Mailbox[S] {
+	return &Mailbox[S]{
+		inbox:   make(chan Envelope[S]),
+		stopped: make(chan struct{}),
+	}
+}
+
+// Inbox is what the actor's loop selects on, next to whatever else it
+// waits for, running every command it receives.
+func (m *Mailbox[S]) Inbox() <-chan Envelope[S] {
+	return m.inbox
+}
+
+// Stop is called by the actor once its loop has returned, so that
+// commands fail with ErrStopped instead of waiting forever. Anything
+// the actor writes before Stop is visible to those who see it stopped.
+func (m *Mailbox[S]) Stop() {
+	close(m.stopped)
+}
+
+// Done is closed once the actor has stopped.
+func (m *Mailbox[S]) Done() <-chan struct{} {
+	return m.stopped
+}
+
+// Ask runs f against the actor's state, inside the actor's loop, and
+// returns its result. A command that asks the actor to stop should
+// set something in the state the loop checks: the loop then stops
+// gracefully, after replying.
+func Ask[S, R any](m *Mailbox[S], f func(*S) R) (R, error) {
+	return AskContext(context.Background(), m, f)
+}
+
+// AskContext is like Ask, but gives up with ctx's error if the actor
+// has not taken the command by the time ctx is done. Once taken, the
+// command runs to completion.
+func AskContext[S, R any](ctx context.Context, m *Mailbox[S], f func(*S) R) (R, error) {
+	reply := make(chan R, 1) // the actor must never wait on us
+	var zero R
+	select {
+	case m.inbox <- Envelope[S]{func(s *S) { reply <- f(s) }}:
+		return <-reply, nil
+	case <-m.stopped:
+		return zero, ErrStopped
+	case <-ctx.Done():
+		return zero, ctx.Err()
+	}
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"slices"
+	"time"
+)
+
+// Blackout is a recurring window during which a feed must not be
+// polled. From and To are times of day, given as offsets from
+// midnight and read off the wall clock, so 6h is 06:00 even on a day
+// the clocks change; a To at or before From wraps past midnight, so
+// {From: 22h, To: 6h} covers the night. Days restricts the window to
+// the days it starts on; empty means every day. Location is the time
+// zone the window is kept in, nil for the clock's own.
+type Blackout struct {
+	From, To time.Duration
+	Days     []time.Weekday
+	Location *time.Location
+}
+
+// Weekends is a blackout covering all of Saturday and Sunday.
+var Weekends = Blackout{To: 24 * time.Hour, Days: []time.Weekday{time.Saturday, time.Sunday}}
+
+// WithBlackouts returns a Fetcher that leaves f alone while any of
+// windows is in effect. Instead of fetching it returns no items and
+// the end of the blackout as next, so the subscription loop simply
+// sleeps through the window like any other fetch delay.
+func WithBlackouts(f Fetcher, windows ...Blackout) Fetcher {
+	return WithBlackoutsClock(f, RealClock, windows...)
+}
+
+// WithBlackoutsClock is like WithBlackouts, with the time taken from
+// clock, which should be the one the subscription runs on.
+func WithBlackoutsClock(f Fetcher, clock Clock, windows ...Blackout) Fetcher {
+	return &blackoutFetcher{fetcher: f, clock: clock, windows: windows}
+}
+
+type blackoutFetcher struct {
+	fetcher Fetcher
+	clock   Clock
+	windows []Blackout
+}
+
+func (f *blackoutFetcher) Fetch() (items []Item, next time.Time, err error) {
+	return f.FetchContext(context.Background())
+}
+
+func (f *blackoutFetcher) FetchContext(ctx context.Context) (items []Item, next time.Time, err error) {
+	now := f.clock.Now()
+	if end := blackoutEnd(f.windows, now); end.After(now) {
+		return nil, end, nil
+	}
+	return fetchContext(ctx, f.fetcher)
+}
+
+// blackoutEnd returns when the blackouts covering t are over, or t
+// itself if none is. Back-to-back windows, like Saturday then Sunday,
+// are followed through, but never more than a week ahead so windows
+// that cover every hour still yield a retry time.
+func blackoutEnd(windows []Blackout, t time.Time) time.Time {
+	limit := t.Add(7 * 24 * time.Hour)
+	for t.Before(limit) {
+		end := t
+		for _, w := range windows {
+			if e, ok := w.activeUntil(t); ok && e.After(end) {
+				end = e
+			}
+		}
+		if end.Equal(t) {
+			break
+		}
+		t = end
+	}
+	return t
+}
+
+// activeUntil reports whether w is in effect at t and, if so, when
+// that occurrence ends. Only windows starting yesterday or today can
+// cover t.
+func (w Blackout) activeUntil(t time.Time) (time.Time, bool) {
+	loc := w.Location
+	if loc == nil {
+		loc = t.Location()
+	}
+	y, m, d := t.In(loc).Date()
+	for _, day := range []int{d - 1, d} {
+		start := timeOfDay(y, m, day, w.From, loc)
+		if len(w.Days) > 0 && !slices.Contains(w.Days, start.Weekday()) {
+			continue
+		}
+		endDay := day
+		if w.To <= w.From {
+			endDay++
+		}
+		end := timeOfDay(y, m, endDay, w.To, loc)
+		if !t.Before(start) && t.Before(end) {
+			return end, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// timeOfDay returns the time the wall clock in loc reads offset past
+// midnight on the given day, which time.Date normalizes, so day 0 is
+// the last of the month before and 24h is midnight of the next day.
+// Adding offset to midnight instead would be an hour off on days the
+// clocks change.
+func timeOfDay(y int, m time.Month, day int, offset time.Duration, loc *time.Location) time.Time {
+	hour := offset / time.Hour
+	minute := offset % time.Hour / time.Minute
+	second := offset % time.Minute / time.Second
+	return time.Date(y, m, day, int(hour), int(minute), int(second), int(offset%time.Second), loc)
+}
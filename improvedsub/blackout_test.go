@@ -0,0 +1,61 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBlackoutFollowsTheWallClock(t *testing.T) {
+	ny, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skip("no time zone data:", err)
+	}
+	night := Blackout{From: 22 * time.Hour, To: 6 * time.Hour}
+	early := Blackout{From: time.Hour, To: 6 * time.Hour, Location: ny}
+	for _, tt := range []struct {
+		name    string
+		windows []Blackout
+		at, end time.Time // end is at if no window is in effect
+	}{
+		// The clocks go forward at 2:00 on 8 March 2026, and back at
+		// 2:00 on 1 November
+		{"spring forward", []Blackout{early},
+			time.Date(2026, 3, 8, 1, 30, 0, 0, ny), time.Date(2026, 3, 8, 6, 0, 0, 0, ny)},
+		{"fall back", []Blackout{early},
+			time.Date(2026, 11, 1, 1, 30, 0, 0, ny), time.Date(2026, 11, 1, 6, 0, 0, 0, ny)},
+		{"before the window", []Blackout{early},
+			time.Date(2026, 3, 8, 0, 59, 0, 0, ny), time.Date(2026, 3, 8, 0, 59, 0, 0, ny)},
+		{"past midnight", []Blackout{night},
+			time.Date(2026, 3, 7, 23, 0, 0, 0, ny), time.Date(2026, 3, 8, 6, 0, 0, 0, ny)},
+		{"wrapping into the 1st", []Blackout{night},
+			time.Date(2026, 3, 1, 3, 0, 0, 0, ny), time.Date(2026, 3, 1, 6, 0, 0, 0, ny)},
+		{"in the window's location", []Blackout{early},
+			time.Date(2026, 3, 8, 7, 0, 0, 0, time.UTC), time.Date(2026, 3, 8, 6, 0, 0, 0, ny)},
+		{"weekend then night", []Blackout{Weekends, night},
+			time.Date(2026, 3, 7, 12, 0, 0, 0, ny), time.Date(2026, 3, 9, 6, 0, 0, 0, ny)},
+	} {
+		if got := blackoutEnd(tt.windows, tt.at); !got.Equal(tt.end) {
+			t.Errorf("%s: blackout at %v ends at %v, want %v", tt.name, tt.at, got, tt.end)
+		}
+	}
+}
+
+func TestBlackoutUsesTheClock(t *testing.T) {
+	start := time.Date(2026, 3, 7, 12, 0, 0, 0, time.UTC) // a Saturday
+	clock := NewFakeClock(start)
+	f := NewScriptedFetcher(clock, Response{Items: []Item{{GUID: "a"}}, Next: time.Hour})
+	b := WithBlackoutsClock(f, clock, Weekends)
+
+	items, next, err := b.Fetch()
+	if err != nil || len(items) != 0 || len(f.Calls()) != 0 {
+		t.Fatalf("Fetch in the blackout = %v, %v; fetched %d times", items, err, len(f.Calls()))
+	}
+	if want := time.Date(2026, 3, 9, 0, 0, 0, 0, time.UTC); !next.Equal(want) {
+		t.Errorf("next = %v, want %v", next, want)
+	}
+
+	clock.Advance(next.Sub(start))
+	if items, _, err := b.Fetch(); err != nil || len(items) != 1 {
+		t.Errorf("Fetch after the blackout = %v, %v; want item a", items, err)
+	}
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"container/list"
+	"hash/fnv"
+	"math"
+	"sync"
+)
+
+// BloomConfig sizes a BloomSeen.
+type BloomConfig struct {
+	Expected      int                 // distinct keys to size the filter for
+	FalsePositive float64             // acceptable rate at Expected keys, e.g. 0.001
+	Front         int                 // recent keys also kept exactly
+	Hash          func(string) uint64 // nil for 64-bit FNV-1a
+}
+
+// BloomStats counts what a BloomSeen decided.
+type BloomStats struct {
+	Added       int // keys accepted as new
+	FrontDrops  int // duplicates found in the exact front
+	FilterDrops int // drops on the filter's word alone, some of them false positives
+}
+
+// BloomSeen is a SeenStore for millions of keys. It keeps a counting
+// bloom filter of every key plus an exact LRU of the most recent ones.
+// Memory stays fixed at roughly a byte per filter counter, paid for
+// with a bounded chance of dropping an item that was never delivered;
+// FilterDrops tells how often the filter alone made the call. It is
+// safe to share between subscriptions.
+type BloomSeen struct {
+	mu     sync.Mutex
+	hash   func(string) uint64
+	counts []uint8 // saturate at 255 and are then never decremented
+	k      int     // probes per key
+	front  *lru
+	stats  BloomStats
+}
+
+// NewBloomSeen returns a BloomSeen sized by c.
+func NewBloomSeen(c BloomConfig) *BloomSeen {
+	n := float64(max(c.Expected, 1))
+	p := c.FalsePositive
+	if p <= 0 || p >= 1 {
+		p = 0.01
+	}
+	m := math.Ceil(-n * math.Log(p) / (math.Ln2 * math.Ln2))
+	k := max(int(math.Round(m/n*math.Ln2)), 1)
+	hash := c.Hash
+	if hash == nil {
+		hash = fnv64a
+	}
+	return &BloomSeen{
+		hash:   hash,
+		counts: make([]uint8, int(m)),
+		k:      k,
+		front:  newLRU(c.Front),
+	}
+}
+
+func (b *BloomSeen) Add(key string) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.front.touch(key) {
+		b.stats.FrontDrops++
+		return false
+	}
+	if b.contains(key) {
+		b.stats.FilterDrops++
+		return false
+	}
+	for _, i := range b.probes(key) {
+		if b.counts[i] < math.MaxUint8 {
+			b.counts[i]++
+		}
+	}
+	b.front.add(key)
+	b.stats.Added++
+	return true
+}
+
+// Forget removes key, so it is accepted as new again, for instance
+// after an item was retracted. Forgetting a key that was never added
+// can make the filter forget others too.
+func (b *BloomSeen) Forget(key string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if !b.contains(key) {
+		return
+	}
+	for _, i := range b.probes(key) {
+		if b.counts[i] < math.MaxUint8 {
+			b.counts[i]--
+		}
+	}
+	b.front.remove(key)
+}
+
+// Stats returns the counts so far.
+func (b *BloomSeen) Stats() BloomStats {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.stats
+}
+
+func (b *BloomSeen) contains(key string) bool {
+	for _, i := range b.probes(key) {
+		if b.counts[i] == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// probes derives the k counter positions of key from a single hash by
+// double hashing.
+func (b *BloomSeen) probes(key string) []int {
+	h := b.hash(key)
+	h1, h2 := uint32(h), uint32(h>>32)|1
+	m := uint32(len(b.counts))
+	idx := make([]int, b.k)
+	for i := range idx {
+		idx[i] = int((h1 + uint32(i)*h2) % m)
+	}
+	return idx
+}
+
+func fnv64a(key string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(key))
+	return h.Sum64()
+}
+
+// lru is a set of at most size keys, dropping the least recently
+// touched. A size of zero or less holds nothing.
+type lru struct {
+	size  int
+	order *list.List // most recent at the front
+	keys  map[string]*list.Element
+}
+
+func newLRU(size int) *lru {
+	return &lru{size: size, order: list.New(), keys: make(map[string]*list.Element)}
+}
+
+// touch reports whether key is held, marking it most recent if so.
+func (l *lru) touch(key string) bool {
+	e, ok := l.keys[key]
+	if ok {
+		l.order.MoveToFront(e)
+	}
+	return ok
+}
+
+func (l *lru) add(key string) {
+	if l.size <= 0 {
+		return
+	}
+	if l.order.Len() >= l.size {
+		oldest := l.order.Back()
+		l.order.Remove(oldest)
+		delete(l.keys, oldest.Value.(string))
+	}
+	l.keys[key] = l.order.PushFront(key)
+}
+
+func (l *lru) remove(key string) {
+	if e, ok := l.keys[key]; ok {
+		l.order.Remove(e)
+		delete(l.keys, key)
+	}
+}
@@ -0,0 +1,366 @@
+package main
+
+import "time"
+
+// SlowReaderPolicy decides what a Broadcast reader does when its
+// buffer is full and another item arrives. Conflate acts even before
+// the buffer is full, so a slow reader gets the latest version of each
+// entry rather than every version of it.
+type SlowReaderPolicy int
+
+const (
+	Block      SlowReaderPolicy = iota // hold up the broadcast until the reader catches up
+	DropOldest                         // discard the oldest buffered item
+	DropNewest                         // discard the arriving item
+	Conflate                           // replace the buffered item with the same GUID, else DropOldest
+)
+
+// Broadcast lets several readers consume one Subscription. Reading
+// Updates() from two goroutines splits the items between them; each
+// reader returned by NewReader instead receives every item.
+type Broadcast struct {
+	sub     Subscription
+	buffer  int
+	policy  SlowReaderPolicy
+	relief  *Pressure // shrinks buffer under pressure, if set
+	join    chan *reader
+	closing chan chan error
+	lags    chan chan map[string]Lag
+	events  chan LagEvent
+	done    chan struct{} // closed once the broadcast is closed
+	err     error         // from closing sub, set before done
+}
+
+// Lag is how far a reader is behind the broadcast: the items buffered
+// for it and how long the oldest of them has been waiting.
+type Lag struct {
+	Items  int
+	Behind time.Duration
+}
+
+// LagLimits is the lag past which a reader counts as lagging. A zero
+// field is not checked.
+type LagLimits struct {
+	Items  int
+	Behind time.Duration
+}
+
+func (l LagLimits) exceeded(lag Lag) bool {
+	return (l.Items > 0 && lag.Items > l.Items) || (l.Behind > 0 && lag.Behind > l.Behind)
+}
+
+// LagEvent reports that a named reader started lagging, or that it
+// caught up again.
+type LagEvent struct {
+	Reader  string
+	Lag     Lag
+	Lagging bool
+}
+
+// NewBroadcast starts broadcasting the items of s. Each reader buffers
+// up to buffer items and applies policy once that is full.
+func NewBroadcast(s Subscription, buffer int, policy SlowReaderPolicy) *Broadcast {
+	return NewRelievedBroadcast(s, buffer, policy, nil)
+}
+
+// NewRelievedBroadcast is like NewBroadcast, but the readers' buffers
+// shrink as p's level rises, halving at each level, so replay buffers
+// give memory back under pressure; policy then applies earlier.
+func NewRelievedBroadcast(s Subscription, buffer int, policy SlowReaderPolicy, p *Pressure) *Broadcast {
+	b := &Broadcast{
+		sub:     s,
+		buffer:  max(buffer, 1),
+		policy:  policy,
+		relief:  p,
+		join:    make(chan *reader),
+		closing: make(chan chan error),
+		lags:    make(chan chan map[string]Lag),
+		events:  make(chan LagEvent, 16),
+		done:    make(chan struct{}),
+	}
+	go b.loop()
+	return b
+}
+
+// NewReader returns a Subscription that receives every item broadcast
+// from now on. Closing it detaches only this reader. When the
+// broadcast subscription ends, the reader delivers what it has
+// buffered and then closes its Updates.
+func (b *Broadcast) NewReader() Subscription {
+	return b.NewNamedReader("", LagLimits{})
+}
+
+// NewNamedReader is like NewReader, but the reader's lag is reported by
+// Lag under name, and a LagEvent is sent whenever it goes past limits
+// or back under them.
+func (b *Broadcast) NewNamedReader(name string, limits LagLimits) Subscription {
+	r := &reader{
+		b:        b,
+		name:     name,
+		limits:   limits,
+		in:       make(chan Item),
+		updates:  make(chan Item),
+		closing:  make(chan chan error),
+		lag:      make(chan chan Lag),
+		finished: make(chan struct{}),
+	}
+	select {
+	case b.join <- r:
+		go r.loop()
+	case <-b.done:
+		close(r.updates)
+		close(r.finished)
+	}
+	return r
+}
+
+// Close closes the broadcast subscription and every reader, and
+// returns the error from closing the subscription. Calling it again
+// returns the same error.
+func (b *Broadcast) Close() error {
+	errc := make(chan error)
+	select {
+	case b.closing <- errc:
+		return <-errc
+	case <-b.done:
+		return b.err
+	}
+}
+
+// Lag returns the current lag of every named reader, or nil once the
+// broadcast is closed.
+func (b *Broadcast) Lag() map[string]Lag {
+	c := make(chan map[string]Lag)
+	select {
+	case b.lags <- c:
+		return <-c
+	case <-b.done:
+		return nil
+	}
+}
+
+// LagEvents delivers the events of all named readers. Events nobody
+// reads are dropped once a few have queued up, so this never slows the
+// readers down.
+func (b *Broadcast) LagEvents() <-chan LagEvent {
+	return b.events
+}
+
+// capacity is how many items a reader may buffer right now.
+func (b *Broadcast) capacity() int {
+	if b.relief == nil {
+		return b.buffer
+	}
+	return b.relief.Shrink(b.buffer)
+}
+
+func (b *Broadcast) emit(e LagEvent) {
+	select {
+	case b.events <- e:
+	default:
+	}
+}
+
+func (b *Broadcast) loop() {
+	var readers []*reader
+	received := b.sub.Updates()
+	var ended bool // received was closed
+
+	for {
+		select {
+		case errc := <-b.closing:
+			b.shutdown(errc)
+			return
+		case r := <-b.join:
+			if ended {
+				close(r.in) // nothing more to come
+				break
+			}
+			readers = append(readers, r)
+		case c := <-b.lags:
+			c <- lagsOf(readers)
+		case it, ok := <-received:
+			if !ok {
+				// Each reader ends once it has delivered what it holds
+				received, ended = nil, true
+				for _, r := range readers {
+					close(r.in)
+				}
+				readers = nil
+				break
+			}
+			// A Block reader may hold us here; a closed one leaves.
+			// Lag and new readers are still served meanwhile: a lagging
+			// reader is just when Lag matters. Readers joining now get
+			// the next item on.
+			active := make([]*reader, 0, len(readers))
+			var joined []*reader
+			for _, r := range readers {
+			send:
+				for {
+					select {
+					case r.in <- it:
+						active = append(active, r)
+						break send
+					case <-r.finished:
+						break send
+					case j := <-b.join:
+						joined = append(joined, j)
+					case c := <-b.lags:
+						c <- lagsOf(readers)
+					case errc := <-b.closing:
+						b.shutdown(errc)
+						return
+					}
+				}
+			}
+			readers = append(active, joined...)
+		}
+	}
+}
+
+// lagsOf asks each named reader for its lag. A reader can always
+// answer, even while it blocks the broadcast loop.
+func lagsOf(readers []*reader) map[string]Lag {
+	lags := make(map[string]Lag)
+	for _, r := range readers {
+		if r.name == "" {
+			continue
+		}
+		lc := make(chan Lag)
+		select {
+		case r.lag <- lc:
+			lags[r.name] = <-lc
+		case <-r.finished:
+		}
+	}
+	return lags
+}
+
+func (b *Broadcast) shutdown(errc chan error) {
+	b.err = b.sub.Close()
+	close(b.done)
+	errc <- b.err
+}
+
+// reader is the per-reader half of a Broadcast: a loop owning that
+// reader's buffer, like sub.loop owns pending.
+type reader struct {
+	b        *Broadcast
+	name     string // empty if lag is not tracked
+	limits   LagLimits
+	in       chan Item // from the broadcast loop, closed when s ends
+	updates  chan Item
+	closing  chan chan error
+	lag      chan chan Lag
+	finished chan struct{} // closed when loop returns
+}
+
+// queued is a buffered item and when it arrived.
+type queued struct {
+	it Item
+	at time.Time
+}
+
+func (r *reader) Updates() <-chan Item {
+	return r.updates
+}
+
+func (r *reader) Close() error {
+	errc := make(chan error)
+	select {
+	case r.closing <- errc:
+		return <-errc
+	case <-r.finished:
+		return nil
+	}
+}
+
+func (r *reader) loop() {
+	defer close(r.finished)
+	source := r.in // nil once the broadcast's subscription has ended
+	var pending []queued
+	var lagging bool
+
+	// Time behind grows with no items moving, so check it on a ticker
+	var tick <-chan time.Time
+	if r.name != "" && r.limits.Behind > 0 {
+		ticker := time.NewTicker(r.limits.Behind / 2)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	for {
+		if source == nil && len(pending) == 0 {
+			close(r.updates)
+			return
+		}
+		if lag := lagOf(pending); r.name != "" && r.limits.exceeded(lag) != lagging {
+			lagging = !lagging
+			r.b.emit(LagEvent{Reader: r.name, Lag: lag, Lagging: lagging})
+		}
+
+		limit := r.b.capacity()
+		var in chan Item
+		if len(pending) < limit || r.b.policy != Block {
+			in = source
+		}
+
+		var first Item
+		var updates chan Item
+		if len(pending) > 0 {
+			first = pending[0].it
+			updates = r.updates
+		}
+
+		select {
+		case errc := <-r.closing:
+			errc <- nil
+			close(r.updates)
+			return
+		case <-r.b.done:
+			close(r.updates)
+			return
+		case it, ok := <-in:
+			if !ok {
+				source = nil
+				break
+			}
+			if r.b.policy == Conflate && conflate(pending, it) {
+				break
+			}
+			if len(pending) >= limit {
+				if r.b.policy == DropNewest {
+					break
+				}
+				// DropOldest or Conflate; the limit may have shrunk
+				pending = pending[len(pending)-limit+1:]
+			}
+			pending = append(pending, queued{it, time.Now()})
+		case updates <- first:
+			pending = pending[1:]
+		case c := <-r.lag:
+			c <- lagOf(pending)
+		case <-tick:
+		}
+	}
+}
+
+// conflate replaces the item in pending with the GUID of it, keeping
+// its place and arrival time, and reports whether there was one.
+func conflate(pending []queued, it Item) bool {
+	for i := range pending {
+		if pending[i].it.GUID == it.GUID {
+			pending[i].it = it
+			return true
+		}
+	}
+	return false
+}
+
+func lagOf(pending []queued) Lag {
+	if len(pending) == 0 {
+		return Lag{}
+	}
+	return Lag{Items: len(pending), Behind: time.Since(pending[0].at)}
+}
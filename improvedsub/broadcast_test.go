@@ -0,0 +1,115 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// chanSub is a Subscription delivering whatever is sent on it; closing
+// the channel ends it.
+type chanSub chan Item
+
+func (c chanSub) Updates() <-chan Item { return c }
+func (c chanSub) Close() error         { return nil }
+
+func TestBroadcastReadersEndWithTheSubscription(t *testing.T) {
+	AssertNoLeaks(t, func() {
+		src := make(chanSub)
+		b := NewBroadcast(src, 4, Block)
+		r1, r2 := b.NewReader(), b.NewReader()
+		for _, g := range []string{"a", "b", "c"} {
+			src <- Item{GUID: g}
+		}
+		close(src)
+		for _, r := range []Subscription{r1, r2} {
+			var got []string
+			timeout := time.After(5 * time.Second)
+		read:
+			for {
+				select {
+				case it, ok := <-r.Updates():
+					if !ok {
+						break read
+					}
+					got = append(got, it.GUID)
+				case <-timeout:
+					t.Fatalf("reader still open after %v", got)
+				}
+			}
+			if strings.Join(got, "") != "abc" {
+				t.Errorf("reader got %v, want [a b c]", got)
+			}
+		}
+		if _, ok := <-b.NewReader().Updates(); ok {
+			t.Error("a reader joining after the end got an item")
+		}
+		if err := b.Close(); err != nil {
+			t.Errorf("Close: %v", err)
+		}
+	})
+}
+
+func TestSSEStreamEndsWithTheSubscription(t *testing.T) {
+	src := make(chanSub)
+	b := NewBroadcast(src, 4, Block)
+	defer b.Close()
+	srv := httptest.NewServer(SSEHandler(b, 0))
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	// The handler has joined a reader by the time we have the headers
+	src <- Item{GUID: "a"}
+	close(src)
+
+	body := make(chan string)
+	go func() {
+		data, _ := io.ReadAll(resp.Body)
+		body <- string(data)
+	}()
+	select {
+	case s := <-body:
+		if !strings.Contains(s, "id: a\n") {
+			t.Errorf("stream was %q, want event a", s)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("stream still open after the subscription ended")
+	}
+}
+
+func TestBroadcastServesLagWhileBlocked(t *testing.T) {
+	AssertNoLeaks(t, func() {
+		src := make(chanSub)
+		b := NewBroadcast(src, 1, Block)
+		defer b.Close()
+		b.NewNamedReader("slow", LagLimits{}) // never read
+		src <- Item{GUID: "a"}                // buffered by slow
+		src <- Item{GUID: "b"}                // holds the loop on slow
+
+		lag := make(chan map[string]Lag)
+		go func() { lag <- b.Lag() }()
+		select {
+		case lags := <-lag:
+			if got := lags["slow"].Items; got != 1 {
+				t.Errorf("slow holds %d items; want 1", got)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("Lag blocked behind a lagging reader")
+		}
+
+		joined := make(chan Subscription)
+		go func() { joined <- b.NewReader() }()
+		select {
+		case <-joined:
+		case <-time.After(5 * time.Second):
+			t.Fatal("NewReader blocked behind a lagging reader")
+		}
+	})
+}
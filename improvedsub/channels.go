@@ -0,0 +1,91 @@
+package main
+
+// OrDone returns a channel that yields the values of c until c is
+// closed or done is, whichever comes first. It lets a consumer range
+// over a stream such as Updates() while still honoring cancellation:
+//
+//	for it := range OrDone(done, sub.Updates()) {
+//		...
+//	}
+//
+// instead of writing the nested select by hand every time.
+func OrDone[T any](done <-chan struct{}, c <-chan T) <-chan T {
+	out := make(chan T)
+	go func() {
+		defer close(out)
+		for {
+			select {
+			case v, ok := <-c:
+				if !ok {
+					return
+				}
+				select {
+				case out <- v:
+				case <-done:
+					return
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+	return out
+}
+
+// Bridge flattens a stream of channels into a single channel, reading
+// each one to the end before moving on to the next. It pairs with
+// restart flows where every resubscription hands out a fresh Updates()
+// channel: the consumer keeps ranging over one stream throughout.
+func Bridge[T any](done <-chan struct{}, chans <-chan (<-chan T)) <-chan T {
+	out := make(chan T)
+	go func() {
+		defer close(out)
+		for {
+			var c <-chan T
+			select {
+			case next, ok := <-chans:
+				if !ok {
+					return
+				}
+				c = next
+			case <-done:
+				return
+			}
+			for v := range OrDone(done, c) {
+				select {
+				case out <- v:
+				case <-done:
+					return
+				}
+			}
+		}
+	}()
+	return out
+}
+
+// Tee duplicates every value of in onto both outputs. Each value is
+// handed to both readers before the next one is taken from in, so the
+// slower reader sets the pace; use Broadcast when readers need to run
+// independently.
+func Tee[T any](done <-chan struct{}, in <-chan T) (<-chan T, <-chan T) {
+	out1 := make(chan T)
+	out2 := make(chan T)
+	go func() {
+		defer close(out1)
+		defer close(out2)
+		for v := range OrDone(done, in) {
+			o1, o2 := out1, out2 // nil out each side once it has v
+			for i := 0; i < 2; i++ {
+				select {
+				case o1 <- v:
+					o1 = nil
+				case o2 <- v:
+					o2 = nil
+				case <-done:
+					return
+				}
+			}
+		}
+	}()
+	return out1, out2
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"sync"
+	"time"
+)
+
+// Checkpoint is how far a feed was delivered: the newest item of the
+// last fetch whose items were all delivered. Feeds list their items
+// newest first, so the last item delivered is no checkpoint: older
+// items of the same fetch may still be pending, and would be lost.
+type Checkpoint struct {
+	GUID      string    `json:"guid"`
+	Published time.Time `json:"published"`
+}
+
+// advance moves c to it if it is newer, or if c is still empty.
+func (c *Checkpoint) advance(it Item) {
+	if c.GUID == "" || it.Published.After(c.Published) {
+		*c = Checkpoint{it.GUID, it.Published}
+	}
+}
+
+// checkpointMark is a Checkpoint the loop reaches once left more items
+// have been delivered: the end of a fetch's items in pending.
+type checkpointMark struct {
+	left int
+	cp   Checkpoint
+}
+
+// loopCheckpoints is the loop's side of SubscribeCheckpointed: where an
+// earlier subscription left off, and the checkpoints the items pending
+// reach once delivered. Its methods do nothing on a nil
+// *loopCheckpoints, so the loop can call them whether or not it is
+// checkpointed.
+type loopCheckpoints struct {
+	feed   string
+	cps    Checkpointer
+	resume *Checkpoint // nil if nothing was saved
+	newest Checkpoint  // the newest item queued so far
+	added  bool        // items queued since the last mark
+	marks  []checkpointMark
+}
+
+// loadCheckpoints returns nil if s is not checkpointed.
+func (s *sub) loadCheckpoints() (*loopCheckpoints, error) {
+	if s.cps == nil {
+		return nil, nil
+	}
+	c := &loopCheckpoints{feed: s.feed, cps: s.cps}
+	cp, ok, err := s.cps.Load(s.feed)
+	if ok {
+		c.resume = &cp
+	}
+	return c, err
+}
+
+// skip reports whether it was delivered before the restart.
+func (c *loopCheckpoints) skip(it Item) bool {
+	return c != nil && c.resume != nil && c.resume.covers(it)
+}
+
+// add records that it was queued.
+func (c *loopCheckpoints) add(it Item) {
+	if c != nil {
+		c.newest.advance(it)
+		c.added = true
+	}
+}
+
+// mark ends a fetch's items, pending being how many are queued now.
+func (c *loopCheckpoints) mark(pending int) {
+	if c != nil && c.added {
+		c.marks = append(c.marks, checkpointMark{pending, c.newest})
+		c.added = false
+	}
+}
+
+// deliver records that the oldest pending item was delivered, saving
+// the checkpoint that completes.
+func (c *loopCheckpoints) deliver() error {
+	if c == nil {
+		return nil
+	}
+	for i := range c.marks {
+		c.marks[i].left--
+	}
+	if len(c.marks) == 0 || c.marks[0].left > 0 {
+		return nil
+	}
+	cp := c.marks[0].cp
+	c.marks = c.marks[1:]
+	return c.cps.Save(c.feed, cp)
+}
+
+// covers reports whether it was delivered before the checkpoint was
+// taken: it is the checkpointed item, or was published no later.
+// Items without a publish time can only be recognized by GUID.
+func (c Checkpoint) covers(it Item) bool {
+	if it.GUID == c.GUID {
+		return true
+	}
+	return !c.Published.IsZero() && !it.Published.IsZero() && !it.Published.After(c.Published)
+}
+
+// Checkpointer keeps a Checkpoint per feed across restarts.
+type Checkpointer interface {
+	Save(feed string, c Checkpoint) error
+	// Load returns false if nothing was saved for feed yet.
+	Load(feed string) (Checkpoint, bool, error)
+}
+
+// SubscribeCheckpointed is like Subscribe, but picks up where an
+// earlier subscription to feed left off. Once all the items of a fetch
+// are delivered, the newest of them is saved as the feed's checkpoint
+// in cp, and fetched items the checkpoint covers are dropped, so after
+// a restart the new subscription does not deliver them again even
+// though its seen map starts out empty. Items of a fetch that was only
+// partly delivered come again: none are missed, though some may be
+// delivered twice. It works best with feeds that set publish times. An
+// error from cp is returned by Close, like a fetch error.
+func SubscribeCheckpointed(fetcher Fetcher, feed string, cp Checkpointer) Subscription {
+	return newSub(fetcher, subOptions{feed: feed, checkpoints: cp})
+}
+
+// FileCheckpoints is a Checkpointer keeping every feed's checkpoint in
+// one JSON file, rewritten atomically on each Save. It suits a single
+// process and feeds delivering a few items a second at most; each Save
+// syncs the file to disk.
+type FileCheckpoints struct {
+	mu   sync.Mutex
+	path string
+	cps  map[string]Checkpoint // nil until read
+}
+
+// NewFileCheckpoints returns a FileCheckpoints kept at path. The file
+// is created on the first Save.
+func NewFileCheckpoints(path string) *FileCheckpoints {
+	return &FileCheckpoints{path: path}
+}
+
+func (f *FileCheckpoints) Save(feed string, c Checkpoint) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.read(); err != nil {
+		return err
+	}
+	f.cps[feed] = c
+	data, err := json.Marshal(f.cps)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(f.path, data)
+}
+
+func (f *FileCheckpoints) Load(feed string) (Checkpoint, bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.read(); err != nil {
+		return Checkpoint{}, false, err
+	}
+	c, ok := f.cps[feed]
+	return c, ok, nil
+}
+
+// read loads the file the first time it is needed.
+func (f *FileCheckpoints) read() error {
+	if f.cps != nil {
+		return nil
+	}
+	data, err := os.ReadFile(f.path)
+	if errors.Is(err, os.ErrNotExist) {
+		f.cps = make(map[string]Checkpoint)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	cps := make(map[string]Checkpoint)
+	if err := json.Unmarshal(data, &cps); err != nil {
+		return err
+	}
+	f.cps = cps
+	return nil
+}
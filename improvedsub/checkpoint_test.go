@@ -0,0 +1,59 @@
+package main
+
+import (
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestCheckpointedRestartMissesNothing(t *testing.T) {
+	cps := NewFileCheckpoints(filepath.Join(t.TempDir(), "checkpoints.json"))
+	t0 := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	old := Item{GUID: "old", Published: t0}
+	newest := []Item{ // newest first, as feeds list them
+		{GUID: "new3", Published: t0.Add(3 * time.Hour)},
+		{GUID: "new2", Published: t0.Add(2 * time.Hour)},
+		{GUID: "new1", Published: t0.Add(time.Hour)},
+	}
+	script := func() *ScriptedFetcher {
+		return NewScriptedFetcher(RealClock,
+			Response{Items: []Item{old}},
+			Response{Items: append(newest, old), Next: time.Hour})
+	}
+
+	// Deliver old and new3, then stop with new2 and new1 pending
+	s := SubscribeCheckpointed(script(), "feed", cps)
+	for _, want := range []string{"old", "new3"} {
+		if it := <-s.Updates(); it.GUID != want {
+			t.Fatalf("got %s, want %s", it.GUID, want)
+		}
+	}
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if c, _, _ := cps.Load("feed"); c.GUID != "old" {
+		t.Errorf("checkpoint is %s with new2 and new1 undelivered, want old", c.GUID)
+	}
+
+	// After a restart, new1 and new2 must still come
+	s = SubscribeCheckpointed(script(), "feed", cps)
+	var got []string
+	for len(got) < 3 {
+		select {
+		case it := <-s.Updates():
+			got = append(got, it.GUID)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("after the restart, only got %v", got)
+		}
+	}
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if slices.Contains(got, "old") || !slices.Contains(got, "new2") || !slices.Contains(got, "new1") {
+		t.Errorf("after the restart got %v, want new3, new2 and new1", got)
+	}
+	if c, _, _ := cps.Load("feed"); c.GUID != "new3" {
+		t.Errorf("checkpoint is %s after everything was delivered, want new3", c.GUID)
+	}
+}
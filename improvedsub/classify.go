@@ -0,0 +1,175 @@
+package main
+
+import (
+	"container/heap"
+	"strings"
+	"sync"
+)
+
+// Classifier scores items, higher meaning more important. Scoring may
+// be slow, a call out to a model say, which is why Classify runs it on
+// several goroutines.
+type Classifier interface {
+	Score(Item) float64
+}
+
+// ClassifierFunc adapts a function to the Classifier interface.
+type ClassifierFunc func(Item) float64
+
+func (f ClassifierFunc) Score(it Item) float64 {
+	return f(it)
+}
+
+// KeywordWeights is the simplest Classifier: an item scores the sum of
+// the weights of the keywords its title contains, ignoring case.
+type KeywordWeights map[string]float64
+
+func (w KeywordWeights) Score(it Item) float64 {
+	title := strings.ToLower(it.Title)
+	var score float64
+	for kw, weight := range w {
+		if strings.Contains(title, strings.ToLower(kw)) {
+			score += weight
+		}
+	}
+	return score
+}
+
+// Classify sets the Score of every item of s using c, on up to workers
+// goroutines at once. Scored items wait in a priority queue, so when
+// the client falls behind the highest scores are delivered first. At
+// most a few items are held at a time, scored or being scored, so a
+// busy classifier slows down reading from s rather than piling up
+// work. Route rules can then select items by score.
+func Classify(s Subscription, c Classifier, workers int) Subscription {
+	m := &classified{
+		sub:     s,
+		updates: make(chan Item),
+		closing: make(chan chan error),
+		done:    make(chan struct{}),
+	}
+	go m.loop(c, max(workers, 1))
+	return m
+}
+
+type classified struct {
+	sub     Subscription
+	updates chan Item
+	closing chan chan error
+	done    chan struct{} // closed when loop returns
+	err     error         // from closing sub, set before done
+}
+
+func (m *classified) Updates() <-chan Item {
+	return m.updates
+}
+
+func (m *classified) Close() error {
+	errc := make(chan error)
+	select {
+	case m.closing <- errc:
+		return <-errc
+	case <-m.done:
+		return m.err
+	}
+}
+
+func (m *classified) loop(c Classifier, workers int) {
+	defer close(m.done)
+	const maxHeld = 10
+	jobs := make(chan Item)
+	results := make(chan Item)
+	quit := make(chan struct{})
+	var wg sync.WaitGroup
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case it := <-jobs:
+					it.Score = c.Score(it)
+					select {
+					case results <- it:
+					case <-quit:
+						return
+					}
+				case <-quit:
+					return
+				}
+			}
+		}()
+	}
+	stop := func() {
+		close(quit)
+		wg.Wait()
+		m.err = m.sub.Close()
+		close(m.updates)
+	}
+
+	source := m.sub.Updates()
+	var pending scoreHeap
+	var job Item
+	var holding bool // job was received and not yet handed to a worker
+	var scoring int  // handed to workers, not back yet
+
+	for {
+		if source == nil && !holding && scoring == 0 && len(pending) == 0 {
+			stop() // s ended and everything from it was delivered
+			return
+		}
+
+		var received <-chan Item
+		if !holding && scoring+len(pending) < maxHeld {
+			received = source
+		}
+		var toWorker chan Item
+		if holding {
+			toWorker = jobs
+		}
+		var first Item
+		var updates chan Item
+		if len(pending) > 0 {
+			first, updates = pending[0], m.updates
+		}
+
+		select {
+		case errc := <-m.closing:
+			stop()
+			errc <- m.err
+			return
+		case it, ok := <-received:
+			if !ok {
+				source = nil
+				break
+			}
+			job, holding = it, true
+		case toWorker <- job:
+			holding = false
+			scoring++
+		case it := <-results:
+			scoring--
+			heap.Push(&pending, it)
+		case updates <- first:
+			heap.Pop(&pending)
+		}
+	}
+}
+
+// scoreHeap is a max-heap of Items ordered by Score.
+type scoreHeap []Item
+
+func (h scoreHeap) Len() int           { return len(h) }
+func (h scoreHeap) Less(i, j int) bool { return h[i].Score > h[j].Score }
+func (h scoreHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+
+func (h *scoreHeap) Push(x any) {
+	*h = append(*h, x.(Item))
+}
+
+func (h *scoreHeap) Pop() any {
+	old := *h
+	it := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return it
+}
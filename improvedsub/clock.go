@@ -0,0 +1,152 @@
+package main
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Clock is where a loop gets the time and its timers from. The loops
+// use the real clock unless given another one, so tests can use a
+// FakeClock and move time forward by hand instead of sleeping.
+type Clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+	NewTimer(d time.Duration) Timer
+}
+
+// Timer is the part of *time.Timer the loops use.
+type Timer interface {
+	C() <-chan time.Time
+	Stop() bool
+	Reset(d time.Duration) bool
+}
+
+// RealClock is the Clock backed by the time package.
+var RealClock Clock = realClock{}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time                         { return time.Now() }
+func (realClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+func (realClock) NewTimer(d time.Duration) Timer         { return realTimer{time.NewTimer(d)} }
+
+type realTimer struct{ *time.Timer }
+
+func (t realTimer) C() <-chan time.Time { return t.Timer.C }
+
+// FakeClock is a Clock that only moves when Advance is called. Timers
+// fire from Advance, in the order they are due, each at most once per
+// arming, like real ones.
+type FakeClock struct {
+	mu     sync.Mutex
+	now    time.Time
+	timers []*fakeTimer // armed ones
+}
+
+// NewFakeClock returns a FakeClock reading now.
+func NewFakeClock(now time.Time) *FakeClock {
+	return &FakeClock{now: now}
+}
+
+func (c *FakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *FakeClock) After(d time.Duration) <-chan time.Time {
+	return c.NewTimer(d).C()
+}
+
+func (c *FakeClock) NewTimer(d time.Duration) Timer {
+	t := &fakeTimer{clock: c, c: make(chan time.Time, 1)}
+	t.Reset(d)
+	return t
+}
+
+// Advance moves the clock forward by d and fires every timer that
+// comes due on the way.
+func (c *FakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+	sort.SliceStable(c.timers, func(i, j int) bool {
+		return c.timers[i].at.Before(c.timers[j].at)
+	})
+	armed := c.timers[:0]
+	for _, t := range c.timers {
+		if t.at.After(c.now) {
+			armed = append(armed, t)
+			continue
+		}
+		select {
+		case t.c <- c.now:
+		default: // the last tick was never read
+		}
+	}
+	clear(c.timers[len(armed):])
+	c.timers = armed
+}
+
+// Timers returns how many timers are waiting to fire, so a test can
+// tell when a loop has gone back to sleep before advancing the clock.
+func (c *FakeClock) Timers() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.timers)
+}
+
+type fakeTimer struct {
+	clock *FakeClock
+	c     chan time.Time
+	at    time.Time
+}
+
+func (t *fakeTimer) C() <-chan time.Time {
+	return t.c
+}
+
+func (t *fakeTimer) Stop() bool {
+	c := t.clock
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	select {
+	case <-t.c: // as with time.Timer, no stale tick after Stop
+	default:
+	}
+	return t.disarm()
+}
+
+// Reset rearms the timer, discarding a tick that was not read yet, as
+// time.Timer.Reset does since Go 1.23.
+func (t *fakeTimer) Reset(d time.Duration) bool {
+	c := t.clock
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	wasArmed := t.disarm()
+	select {
+	case <-t.c:
+	default:
+	}
+	t.at = c.now.Add(d)
+	if d <= 0 {
+		t.c <- c.now
+		return wasArmed
+	}
+	c.timers = append(c.timers, t)
+	return wasArmed
+}
+
+// disarm takes t off the clock and reports whether it was on it. The
+// clock's mutex must be held.
+func (t *fakeTimer) disarm() bool {
+	c := t.clock
+	for i, o := range c.timers {
+		if o == t {
+			c.timers = append(c.timers[:i], c.timers[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
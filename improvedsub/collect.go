@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrNoMatch is returned by WaitFor when the subscription ends before
+// a matching item arrives.
+var ErrNoMatch = errors.New("subscription ended without a matching item")
+
+// WaitOption changes how WaitFor treats its subscription.
+type WaitOption int
+
+const (
+	// LeaveOpen keeps the subscription open when WaitFor returns, so
+	// the caller can go on reading from it; it is then theirs to close.
+	LeaveOpen WaitOption = iota + 1
+)
+
+// CollectN reads items from s until it has n of them, s ends or ctx
+// is done, and closes s in every case. The error is ctx's if it
+// expired first, otherwise the one Close returned.
+func CollectN(ctx context.Context, s Subscription, n int) ([]Item, error) {
+	return collect(ctx, s, n, nil)
+}
+
+// CollectFor is like CollectN, but reads for d instead of up to a
+// count.
+func CollectFor(ctx context.Context, s Subscription, d time.Duration) ([]Item, error) {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	return collect(ctx, s, -1, t.C)
+}
+
+// collect reads up to n items, or without limit if n is negative,
+// until stop fires.
+func collect(ctx context.Context, s Subscription, n int, stop <-chan time.Time) (items []Item, err error) {
+	defer func() {
+		if cerr := s.Close(); err == nil {
+			err = cerr
+		}
+	}()
+	for n < 0 || len(items) < n {
+		select {
+		case it, ok := <-s.Updates():
+			if !ok {
+				return items, nil
+			}
+			items = append(items, it)
+		case <-stop:
+			return items, nil
+		case <-ctx.Done():
+			return items, ctx.Err()
+		}
+	}
+	return items, nil
+}
+
+// WaitFor discards items from s until one satisfies match, s ends or
+// ctx is done. s is closed before returning unless LeaveOpen is given;
+// a Close error is only reported when nothing else went wrong.
+func WaitFor(ctx context.Context, s Subscription, match func(Item) bool, opts ...WaitOption) (it Item, err error) {
+	leaveOpen := false
+	for _, o := range opts {
+		leaveOpen = leaveOpen || o == LeaveOpen
+	}
+	if !leaveOpen {
+		defer func() {
+			if cerr := s.Close(); err == nil {
+				err = cerr
+			}
+		}()
+	}
+	for {
+		select {
+		case it, ok := <-s.Updates():
+			if !ok {
+				return Item{}, ErrNoMatch
+			}
+			if match(it) {
+				return it, nil
+			}
+		case <-ctx.Done():
+			return Item{}, ctx.Err()
+		}
+	}
+}
+
+// ErrCloseTimeout is returned, wrapping the context's error, when
+// CloseContext gives up waiting.
+var ErrCloseTimeout = errors.New("close timed out")
+
+// CloseContext closes s, giving up once ctx is done so a shutdown path
+// can't hang on a stuck subscription. It uses s's own CloseContext if
+// it has one; otherwise Close is left running in the background.
+func CloseContext(ctx context.Context, s Subscription) error {
+	if c, ok := s.(interface{ CloseContext(context.Context) error }); ok {
+		return c.CloseContext(ctx)
+	}
+	errc := make(chan error, 1)
+	go func() { errc <- s.Close() }()
+	select {
+	case err := <-errc:
+		return err
+	case <-ctx.Done():
+		return fmt.Errorf("%w: %w", ErrCloseTimeout, ctx.Err())
+	}
+}
+
+// CloseAndDrain closes s and returns the items it had fetched but not
+// yet delivered, using s's own CloseAndDrain if it has one. Other
+// subscriptions are just closed, and nothing is returned.
+func CloseAndDrain(s Subscription) ([]Item, error) {
+	if d, ok := s.(interface{ CloseAndDrain() ([]Item, error) }); ok {
+		return d.CloseAndDrain()
+	}
+	return nil, s.Close()
+}
@@ -0,0 +1,104 @@
+package main
+
+import "time"
+
+// Debounce wraps s and holds back bursts: an item is only passed on
+// once s has been quiet for d after it, and any item followed sooner
+// by another is dropped in favor of the newer one. A feed spamming
+// micro-updates thus yields one item per burst, its last. An item
+// still waiting when s ends is passed on right away.
+//
+// Like loop, it keeps one timer, reset by each item and only read in
+// the select while an item is waiting for quiet.
+func Debounce(s Subscription, d time.Duration) Subscription {
+	b := &debounced{
+		sub:     s,
+		quiet:   d,
+		updates: make(chan Item),
+		closing: make(chan chan error),
+		done:    make(chan struct{}),
+	}
+	go b.loop()
+	return b
+}
+
+type debounced struct {
+	sub     Subscription
+	quiet   time.Duration
+	updates chan Item
+	closing chan chan error
+	done    chan struct{} // closed when loop returns
+	err     error         // from closing sub, set before done
+}
+
+func (b *debounced) Updates() <-chan Item {
+	return b.updates
+}
+
+func (b *debounced) Close() error {
+	errc := make(chan error)
+	select {
+	case b.closing <- errc:
+		return <-errc
+	case <-b.done:
+		return b.err
+	}
+}
+
+func (b *debounced) loop() {
+	defer close(b.done)
+	stop := func() {
+		b.err = b.sub.Close()
+		close(b.updates)
+	}
+
+	// As in sub.loop, one timer for the whole loop: reset by each item
+	// and only selected on while an item is waiting for quiet.
+	timer := time.NewTimer(b.quiet)
+	timer.Stop()
+	defer timer.Stop()
+
+	received := b.sub.Updates()
+	var last Item         // newest item, waiting for quiet
+	var waiting bool      // last is set and the timer armed
+	var first Item        // settled item, waiting to be delivered
+	var updates chan Item // non-nil while first is set
+
+	for {
+		if received == nil && !waiting && updates == nil {
+			stop() // s ended and everything from it was delivered
+			return
+		}
+
+		var settled <-chan time.Time
+		if waiting {
+			settled = timer.C
+		}
+
+		select {
+		case errc := <-b.closing:
+			stop()
+			errc <- b.err
+			return
+		case it, ok := <-received:
+			if !ok {
+				received = nil
+				if waiting {
+					timer.Stop()
+					first, updates = last, b.updates
+					waiting = false
+				}
+				break
+			}
+			last, waiting = it, true
+			timer.Reset(b.quiet)
+		case <-settled:
+			// A newer settled item replaces one the consumer has not
+			// taken yet: it is the last of a later burst.
+			first, updates = last, b.updates
+			waiting = false
+		case updates <- first:
+			updates = nil
+		}
+	}
+}
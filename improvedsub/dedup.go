@@ -0,0 +1,252 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"slices"
+)
+
+// ByGUID identifies items by their GUID.
+func ByGUID(it Item) string {
+	return it.GUID
+}
+
+// ByContent identifies items by a hash of their title, so the same post
+// syndicated under different GUIDs is still recognized.
+func ByContent(it Item) string {
+	h := sha256.Sum256([]byte(it.Title))
+	return hex.EncodeToString(h[:])
+}
+
+// Dedup wraps s, typically a merged subscription, and drops any item
+// whose key was already delivered. The seen map in sub.loop only
+// dedups within a single feed; Dedup works across all of them. Only the
+// last window keys are remembered, so memory stays bounded; a window of
+// zero or less remembers every key. When s ends on its own, so does the
+// Deduper, once the item it holds is delivered.
+func Dedup(s Subscription, key func(Item) string, window int) *Deduper {
+	return DedupSeen(s, key, newSeenWindow(window))
+}
+
+// DedupSeen is like Dedup, with the keys kept in seen.
+func DedupSeen(s Subscription, key func(Item) string, seen SeenStore) *Deduper {
+	d := &Deduper{
+		sub:     s,
+		key:     key,
+		seen:    seen,
+		updates: make(chan Item),
+		closing: make(chan chan error),
+		stats:   make(chan chan DedupStats),
+		resize:  make(chan resizeRequest),
+		done:    make(chan struct{}),
+	}
+	go d.loop()
+	return d
+}
+
+// Deduper is the Subscription returned by Dedup. Its counters and
+// window can be inspected and changed while it runs, since the right
+// window size depends on the workload.
+type Deduper struct {
+	sub     Subscription
+	key     func(Item) string
+	seen    SeenStore
+	updates chan Item
+	closing chan chan error
+	stats   chan chan DedupStats
+	resize  chan resizeRequest
+	done    chan struct{} // closed when loop returns
+	err     error         // from closing sub, set before done
+}
+
+// DedupStats counts what a Deduper has seen.
+type DedupStats struct {
+	Delivered int // items passed on
+	Dropped   int // duplicates
+	Keys      int // keys remembered now, -1 if the store can't tell
+	Window    int // the window size, 0 if unbounded or not a window
+	Evicted   int // keys forgotten to stay within the window
+}
+
+// HitRate is the share of received items that were duplicates.
+func (s DedupStats) HitRate() float64 {
+	if n := s.Delivered + s.Dropped; n > 0 {
+		return float64(s.Dropped) / float64(n)
+	}
+	return 0
+}
+
+type resizeRequest struct {
+	window int
+	errc   chan error
+}
+
+// ErrNotWindow is returned by Resize when the Deduper was built by
+// DedupSeen with a store that has no window.
+var ErrNotWindow = errors.New("dedup: store has no window to resize")
+
+// ErrClosed is returned by Resize after Close.
+var ErrClosed = errors.New("dedup: closed")
+
+func (d *Deduper) Updates() <-chan Item {
+	return d.updates
+}
+
+// Close closes the wrapped subscription. It can be called more than
+// once, from any goroutine; every call returns the same error.
+func (d *Deduper) Close() error {
+	errc := make(chan error)
+	select {
+	case d.closing <- errc:
+		return <-errc
+	case <-d.done:
+		return d.err
+	}
+}
+
+// Stats returns the counters so far; after Close, the zero value.
+func (d *Deduper) Stats() DedupStats {
+	c := make(chan DedupStats)
+	select {
+	case d.stats <- c:
+		return <-c
+	case <-d.done:
+		return DedupStats{}
+	}
+}
+
+// Resize changes the window without a restart. Shrinking forgets the
+// oldest keys, which count as evicted.
+func (d *Deduper) Resize(window int) error {
+	errc := make(chan error)
+	select {
+	case d.resize <- resizeRequest{window, errc}:
+		return <-errc
+	case <-d.done:
+		return ErrClosed
+	}
+}
+
+func (d *Deduper) loop() {
+	defer close(d.done)
+	stop := func() {
+		d.err = d.sub.Close()
+		close(d.updates)
+	}
+
+	source := d.sub.Updates() // nil once it has ended
+	received := source
+	var first Item
+	var updates chan Item
+	var stats DedupStats
+
+	for {
+		if source == nil && updates == nil {
+			stop() // s ended and everything from it was delivered
+			return
+		}
+
+		select {
+		case errc := <-d.closing:
+			stop()
+			errc <- d.err
+			return
+		case it, ok := <-received:
+			if !ok {
+				source, received = nil, nil
+				break
+			}
+			if !d.seen.Add(d.key(it)) {
+				stats.Dropped++
+				break
+			}
+			first, updates = it, d.updates
+			received = nil // hold off until first is delivered
+		case updates <- first:
+			stats.Delivered++
+			updates = nil
+			received = source
+		case c := <-d.stats:
+			c <- d.snapshot(stats)
+		case r := <-d.resize:
+			w, ok := d.seen.(*seenWindow)
+			if !ok {
+				r.errc <- ErrNotWindow
+				break
+			}
+			w.resize(r.window)
+			r.errc <- nil
+		}
+	}
+}
+
+func (d *Deduper) snapshot(stats DedupStats) DedupStats {
+	stats.Keys = -1
+	if w, ok := d.seen.(*seenWindow); ok {
+		stats.Keys, stats.Window, stats.Evicted = len(w.keys), cap(w.ring), w.evicted
+	}
+	return stats
+}
+
+// seenWindow remembers the most recent keys added to it, forgetting
+// the oldest once it holds size of them. A size of zero or less
+// never forgets.
+type seenWindow struct {
+	keys    map[string]bool
+	ring    []string // insertion order, oldest at next
+	next    int
+	evicted int
+}
+
+func newSeenWindow(size int) *seenWindow {
+	size = max(size, 0)
+	return &seenWindow{
+		keys: make(map[string]bool, size),
+		ring: make([]string, 0, size),
+	}
+}
+
+func (w *seenWindow) Add(key string) bool {
+	if w.keys[key] {
+		return false
+	}
+	switch {
+	case cap(w.ring) == 0: // unbounded
+	case len(w.ring) < cap(w.ring):
+		w.ring = append(w.ring, key)
+	default:
+		delete(w.keys, w.ring[w.next])
+		w.evicted++
+		w.ring[w.next] = key
+		w.next = (w.next + 1) % len(w.ring)
+	}
+	w.keys[key] = true
+	return true
+}
+
+// resize keeps the newest size keys, or all of them if size is zero or
+// less. An unbounded window has no insertion order, so bounding one
+// keeps an arbitrary size of its keys.
+func (w *seenWindow) resize(size int) {
+	size = max(size, 0)
+	var order []string
+	if cap(w.ring) == 0 {
+		for k := range w.keys {
+			order = append(order, k)
+		}
+	} else {
+		order = slices.Concat(w.ring[w.next:], w.ring[:w.next])
+	}
+	if size > 0 && len(order) > size {
+		for _, k := range order[:len(order)-size] {
+			delete(w.keys, k)
+			w.evicted++
+		}
+		order = order[len(order)-size:]
+	}
+	w.ring, w.next = make([]string, 0, size), 0
+	if size > 0 {
+		w.ring = append(w.ring, order...)
+	}
+}
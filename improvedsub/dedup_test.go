@@ -0,0 +1,41 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDedupEndsWithItsSubscription(t *testing.T) {
+	AssertNoLeaks(t, func() {
+		clock := NewFakeClock(time.Now())
+		f := NewScriptedFetcher(clock, Response{
+			Items: []Item{{GUID: "a", Title: "A"}, {GUID: "b", Title: "B"}},
+			Next:  time.Hour,
+		})
+		s := newSub(f, subOptions{window: time.Minute, clock: clock})
+		d := Dedup(s, ByContent, 0)
+		for len(f.Calls()) == 0 {
+			time.Sleep(time.Millisecond)
+		}
+		clock.Advance(time.Minute) // the window ends after one fetch
+		done := make(chan []string)
+		go func() {
+			var got []string
+			for it := range d.Updates() {
+				got = append(got, it.Title)
+			}
+			done <- got
+		}()
+		select {
+		case got := <-done:
+			if len(got) != 2 {
+				t.Errorf("got %v, want [A B]", got)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("Updates still open after the subscription ended")
+		}
+		if err := d.Close(); err != nil {
+			t.Errorf("Close: %v", err)
+		}
+	})
+}
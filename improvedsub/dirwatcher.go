@@ -0,0 +1,75 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// WatchDir returns a StreamFetcher that emits an Item for every file
+// created or modified in dir. It polls the directory every interval
+// rather than relying on OS notifications, so it has no dependencies
+// beyond the standard library. An interval that is not positive means
+// every second.
+func WatchDir(dir string, interval time.Duration) StreamFetcher {
+	if interval <= 0 {
+		interval = time.Second
+	}
+	return &dirWatcher{dir: dir, interval: interval}
+}
+
+type dirWatcher struct {
+	dir      string
+	interval time.Duration
+}
+
+func (w *dirWatcher) Stream(done <-chan struct{}, items chan<- Item) error {
+	seen := make(map[string]time.Time) // file name -> last modification, as of the last scan
+	tick := time.NewTicker(w.interval)
+	defer tick.Stop()
+
+	for {
+		entries, err := os.ReadDir(w.dir)
+		if err != nil {
+			return err
+		}
+		// Only files still there are kept, so seen does not grow with
+		// every file that ever passed through, and one that is deleted
+		// and created again is reported again.
+		scanned := make(map[string]time.Time, len(entries))
+		for _, e := range entries {
+			if e.IsDir() {
+				continue
+			}
+			info, err := e.Info()
+			if err != nil {
+				continue // removed since ReadDir
+			}
+			mod := info.ModTime()
+			scanned[e.Name()] = mod
+			if last, ok := seen[e.Name()]; ok && last.Equal(mod) {
+				continue
+			}
+			path := filepath.Join(w.dir, e.Name())
+			item := Item{
+				Channel:   w.dir,
+				Title:     e.Name(),
+				GUID:      fmt.Sprintf("%s@%d", path, mod.UnixNano()),
+				Published: mod,
+			}
+			select {
+			case items <- item:
+			case <-done:
+				return nil
+			}
+		}
+		seen = scanned
+
+		select {
+		case <-tick.C:
+		case <-done:
+			return nil
+		}
+	}
+}
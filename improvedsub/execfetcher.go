@@ -0,0 +1,156 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"slices"
+	"time"
+)
+
+// Command describes a program run on every fetch. It must write one
+// JSON object per line to stdout:
+//
+//	{"title": "...", "guid": "...", "channel": "...", "published": "2006-01-02T15:04:05Z"}
+//
+// Only title is required. The channel defaults to Command.Channel and
+// the guid to channel + "/" + title.
+type Command struct {
+	Path      string
+	Args      []string
+	Channel   string
+	Env       []string      // KEY=value pairs added to the inherited environment
+	Timeout   time.Duration // kills the command after this long, defaults to 30s
+	Interval  time.Duration // time between runs, defaults to 1m
+	MaxOutput int64         // bytes of stdout parsed, defaults to 1MB
+	Tags      []string      // added to every item
+}
+
+// FetchCommand returns a Fetcher that runs c and parses its output.
+// Output past MaxOutput is ignored: the items on the lines before the
+// cap are returned, and the line it cuts through is dropped whole.
+// It is the escape hatch for sources no other fetcher covers. Beyond
+// the timeout and the output cap, limits on CPU or memory are best
+// applied by the command itself, e.g. by wrapping it in prlimit.
+func FetchCommand(c Command) Fetcher {
+	if c.Channel == "" {
+		c.Channel = c.Path
+	}
+	if c.Timeout <= 0 {
+		c.Timeout = 30 * time.Second
+	}
+	if c.Interval <= 0 {
+		c.Interval = time.Minute
+	}
+	if c.MaxOutput <= 0 {
+		c.MaxOutput = 1 << 20
+	}
+	c.Tags = slices.Clip(c.Tags) // shared by all items
+	return &execFetcher{cmd: c}
+}
+
+type execFetcher struct {
+	cmd Command
+}
+
+func (f *execFetcher) Fetch() (items []Item, next time.Time, err error) {
+	return f.FetchContext(context.Background())
+}
+
+// FetchContext kills the command if ctx is done before it finishes.
+func (f *execFetcher) FetchContext(ctx context.Context) (items []Item, next time.Time, err error) {
+	next = time.Now().Add(f.cmd.Interval)
+
+	ctx, cancel := context.WithTimeout(ctx, f.cmd.Timeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, f.cmd.Path, f.cmd.Args...)
+	cmd.Env = append(os.Environ(), f.cmd.Env...)
+	cmd.WaitDelay = time.Second
+	var stderr bytes.Buffer
+	cmd.Stderr = &limitedWriter{w: &stderr, n: 4096}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, next, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, next, err
+	}
+
+	items, parseErr := f.parse(stdout)
+	io.Copy(io.Discard, stdout) // let the command finish writing
+	if err := cmd.Wait(); err != nil {
+		if ctx.Err() != nil {
+			err = ctx.Err()
+		}
+		return nil, next, fmt.Errorf("command %s: %v: %s", f.cmd.Path, err, bytes.TrimSpace(stderr.Bytes()))
+	}
+	if parseErr != nil {
+		return nil, next, fmt.Errorf("command %s: %v", f.cmd.Path, parseErr)
+	}
+	return items, next, nil
+}
+
+// parse reads items from r, one per line, stopping at the last whole
+// line within MaxOutput bytes.
+func (f *execFetcher) parse(r io.Reader) ([]Item, error) {
+	var items []Item
+	sc := bufio.NewScanner(r)
+	// A line as long as the cap, plus its newline, must fit
+	sc.Buffer(make([]byte, 0, min(64<<10, f.cmd.MaxOutput+1)), int(f.cmd.MaxOutput)+1)
+	var read int64
+	for sc.Scan() {
+		if read += int64(len(sc.Bytes())); read > f.cmd.MaxOutput {
+			return items, nil // the cap cuts through this line
+		}
+		read++ // the newline
+		line := bytes.TrimSpace(sc.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		var e struct {
+			Title, GUID, Channel string
+			Published            time.Time
+		}
+		if err := json.Unmarshal(line, &e); err != nil {
+			return nil, err
+		}
+		if e.Title == "" {
+			return nil, fmt.Errorf("item without title: %s", line)
+		}
+		item := Item{Title: e.Title, Channel: e.Channel, GUID: e.GUID, Published: e.Published}
+		if item.Channel == "" {
+			item.Channel = f.cmd.Channel
+		}
+		if item.GUID == "" {
+			item.GUID = item.Channel + "/" + item.Title
+		}
+		item.Tags = f.cmd.Tags
+		items = append(items, item)
+	}
+	if errors.Is(sc.Err(), bufio.ErrTooLong) {
+		return items, nil // a line longer than the cap
+	}
+	return items, sc.Err()
+}
+
+// limitedWriter keeps the first n bytes written to it and discards
+// the rest, so a chatty command cannot grow stderr without bound.
+type limitedWriter struct {
+	w io.Writer
+	n int
+}
+
+func (l *limitedWriter) Write(p []byte) (int, error) {
+	if l.n > 0 {
+		k := min(len(p), l.n)
+		l.w.Write(p[:k])
+		l.n -= k
+	}
+	return len(p), nil
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+// shell returns a Command printing out through sh.
+func shell(out string, maxOutput int64) Command {
+	return Command{
+		Path:      "sh",
+		Args:      []string{"-c", `printf '%s' "$OUT"`},
+		Env:       []string{"OUT=" + out},
+		Channel:   "test",
+		MaxOutput: maxOutput,
+	}
+}
+
+func TestFetchCommandStopsAtTheCapOnALineBoundary(t *testing.T) {
+	out := `{"title": "one"}` + "\n" + `{"title": "two"}` + "\n" + `{"title": "three"}` + "\n"
+	tests := []struct {
+		maxOutput int64
+		want      []string
+	}{
+		{int64(len(out)), []string{"one", "two", "three"}},
+		{int64(len(out)) - 5, []string{"one", "two"}}, // cuts through three
+		{17, []string{"one"}},                         // exactly the first line
+		{3, nil},
+	}
+	for _, tt := range tests {
+		items, _, err := FetchCommand(shell(out, tt.maxOutput)).Fetch()
+		if err != nil {
+			t.Errorf("MaxOutput %d: %v", tt.maxOutput, err)
+			continue
+		}
+		if got := titles(items); strings.Join(got, " ") != strings.Join(tt.want, " ") {
+			t.Errorf("MaxOutput %d: got %q, want %q", tt.maxOutput, got, tt.want)
+		}
+	}
+}
+
+func TestFetchCommandReadsLongLines(t *testing.T) {
+	long := strings.Repeat("x", 100<<10) // past bufio.Scanner's default
+	items, _, err := FetchCommand(shell(`{"title": "`+long+`"}`+"\n", 0)).Fetch()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 1 || items[0].Title != long {
+		t.Fatalf("got %d items; want the one long one", len(items))
+	}
+}
+
+func TestFetchCommandRejectsMalformedOutput(t *testing.T) {
+	if _, _, err := FetchCommand(shell("not json\n", 0)).Fetch(); err == nil {
+		t.Fatal("no error for output that is not JSON")
+	}
+}
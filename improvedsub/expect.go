@@ -0,0 +1,32 @@
+package main
+
+// ExpectOrdered fails t unless items are sorted by less, such as
+// items collected from an OrderedMerge by Published. It reports the
+// first pair out of order.
+func ExpectOrdered(t Failer, items []Item, less func(a, b Item) bool) {
+	t.Helper()
+	for i := 1; i < len(items); i++ {
+		if less(items[i], items[i-1]) {
+			t.Errorf("items out of order at %d: %q (%s) before %q (%s)",
+				i, items[i-1].GUID, items[i-1].Published, items[i].GUID, items[i].Published)
+			return
+		}
+	}
+}
+
+// ExpectNoDuplicates fails t if two items have the same GUID,
+// reporting every GUID delivered more than once.
+func ExpectNoDuplicates(t Failer, items []Item) {
+	t.Helper()
+	seen := make(map[string]int)
+	var dups []string
+	for _, it := range items {
+		seen[it.GUID]++
+		if seen[it.GUID] == 2 {
+			dups = append(dups, it.GUID)
+		}
+	}
+	if len(dups) > 0 {
+		t.Errorf("%d GUIDs delivered more than once: %q", len(dups), dups)
+	}
+}
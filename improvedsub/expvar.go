@@ -0,0 +1,81 @@
+package main
+
+import (
+	"expvar"
+	"sync"
+)
+
+// SubscribeExpvar is like Subscribe, but publishes the subscription's
+// counters as expvars, so /debug/vars on http.DefaultServeMux shows
+// them without any metrics library. They go in the "subscriptions"
+// map under feed:
+//
+//	"subscriptions": {"blog.golang.org": {"delivered": 12, "errors": 1, "fetches": 30, "pending": 2}}
+//
+// Subscriptions sharing a feed name add up into the same counters.
+func SubscribeExpvar(fetcher Fetcher, feed string) Subscription {
+	return newSub(fetcher, subOptions{feed: feed, vars: feedVars(feed)})
+}
+
+var (
+	subscriptionVarsOnce sync.Once
+	subscriptionVars     *expvar.Map
+	subscriptionVarsMu   sync.Mutex // makes feedVars' get-or-create atomic
+)
+
+// subVars are one feed's counters. Its methods do nothing on a nil
+// *subVars, so the loop can call them whether or not it is published.
+type subVars struct {
+	delivered, fetches, errors, pending *expvar.Int
+	reported                            int // this subscription's share of pending, kept by its loop
+}
+
+// feedVars returns the counters for feed, publishing them on first use.
+// Every call gets a *subVars of its own over the shared counters.
+func feedVars(feed string) *subVars {
+	subscriptionVarsOnce.Do(func() {
+		subscriptionVars = expvar.NewMap("subscriptions")
+	})
+	subscriptionVarsMu.Lock()
+	defer subscriptionVarsMu.Unlock()
+	m, ok := subscriptionVars.Get(feed).(*expvar.Map)
+	if !ok {
+		m = new(expvar.Map).Init()
+		for _, name := range []string{"delivered", "fetches", "errors", "pending"} {
+			m.Set(name, new(expvar.Int))
+		}
+		subscriptionVars.Set(feed, m)
+	}
+	return &subVars{
+		delivered: m.Get("delivered").(*expvar.Int),
+		fetches:   m.Get("fetches").(*expvar.Int),
+		errors:    m.Get("errors").(*expvar.Int),
+		pending:   m.Get("pending").(*expvar.Int),
+	}
+}
+
+func (v *subVars) fetched(err error) {
+	if v == nil {
+		return
+	}
+	v.fetches.Add(1)
+	if err != nil {
+		v.errors.Add(1)
+	}
+}
+
+func (v *subVars) deliver() {
+	if v != nil {
+		v.delivered.Add(1)
+	}
+}
+
+// setPending moves the pending gauge by the change in this loop's queue
+// rather than setting it, so subscriptions sharing it each contribute
+// their own.
+func (v *subVars) setPending(n int) {
+	if v != nil && n != v.reported {
+		v.pending.Add(int64(n - v.reported))
+		v.reported = n
+	}
+}
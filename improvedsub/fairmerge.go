@@ -0,0 +1,133 @@
+package main
+
+import "reflect"
+
+// FairMerge is like Merge, but services its children round-robin. In
+// Merge every forwarder contends for the same updates channel, so a
+// chatty feed can win most of the sends and starve the others. Here a
+// single loop keeps a small buffer per child and, whenever more than
+// one child has items ready, takes turns between them.
+func FairMerge(subs ...Subscription) Subscription {
+	return newBufferedMerge(subs, func(buffered [][]Item, last int) int {
+		for k := range buffered {
+			if i := (last + 1 + k) % len(buffered); len(buffered[i]) > 0 {
+				return i
+			}
+		}
+		return -1
+	})
+}
+
+// bufferedMerge merges its children through a single loop that keeps
+// a small buffer per child, and lets next choose which child's item
+// is offered to the client, given the child served last. next returns
+// -1 when all buffers are empty. Once every child has ended and its
+// buffer is drained, the children are closed and so is Updates.
+type bufferedMerge struct {
+	subs    []Subscription
+	next    func(buffered [][]Item, last int) int
+	updates chan Item
+	closing chan chan error
+	done    chan struct{} // closed when loop returns
+	err     error         // from closing the children, set before done
+}
+
+func newBufferedMerge(subs []Subscription, next func([][]Item, int) int) *bufferedMerge {
+	m := &bufferedMerge{
+		subs:    subs,
+		next:    next,
+		updates: make(chan Item),
+		closing: make(chan chan error),
+		done:    make(chan struct{}),
+	}
+	go m.loop()
+	return m
+}
+
+func (m *bufferedMerge) Updates() <-chan Item {
+	return m.updates
+}
+
+func (m *bufferedMerge) Close() error {
+	errc := make(chan error)
+	select {
+	case m.closing <- errc:
+		return <-errc
+	case <-m.done:
+		return m.err
+	}
+}
+
+// loop uses reflect.Select because the number of children, and so the
+// number of cases, is only known at run time. Receive cases are only
+// added for children whose buffer has room, the same trick as the nil
+// channel cases in sub.loop.
+func (m *bufferedMerge) loop() {
+	defer close(m.done)
+	const maxBuffered = 4
+	buffered := make([][]Item, len(m.subs))
+	open := make([]bool, len(m.subs))
+	for i := range open {
+		open[i] = true
+	}
+	last := -1 // child served last
+	live := len(m.subs)
+	stop := func() {
+		for _, s := range m.subs {
+			if e := s.Close(); e != nil {
+				m.err = e
+			}
+		}
+		close(m.updates)
+	}
+
+	for {
+		next := m.next(buffered, last)
+		if live == 0 && next < 0 {
+			stop() // every child ended and everything was delivered
+			return
+		}
+
+		cases := []reflect.SelectCase{{
+			Dir:  reflect.SelectRecv,
+			Chan: reflect.ValueOf(m.closing),
+		}}
+		if next >= 0 {
+			cases = append(cases, reflect.SelectCase{
+				Dir:  reflect.SelectSend,
+				Chan: reflect.ValueOf(m.updates),
+				Send: reflect.ValueOf(buffered[next][0]),
+			})
+		}
+
+		var children []int // child for each receive case below
+		for i, s := range m.subs {
+			if open[i] && len(buffered[i]) < maxBuffered {
+				children = append(children, i)
+				cases = append(cases, reflect.SelectCase{
+					Dir:  reflect.SelectRecv,
+					Chan: reflect.ValueOf(s.Updates()),
+				})
+			}
+		}
+
+		chosen, v, ok := reflect.Select(cases)
+		switch {
+		case chosen == 0:
+			stop()
+			v.Interface().(chan error) <- m.err
+			return
+		case next >= 0 && chosen == 1:
+			buffered[next] = buffered[next][1:]
+			last = next
+		default:
+			i := children[chosen-len(cases)+len(children)]
+			if !ok {
+				open[i] = false
+				live--
+				break
+			}
+			buffered[i] = append(buffered[i], v.Interface().(Item))
+		}
+	}
+}
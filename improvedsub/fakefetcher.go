@@ -11,22 +11,35 @@ func Fetch(domain string) Fetcher {
 }
 
 func fakeFetch(domain string) Fetcher {
-	return &fakeFetcher{channel: domain}
+	seed := FakeSeed ^ int64(fnv64a(domain))
+	return &fakeFetcher{channel: domain, rand: rand.New(rand.NewSource(seed))}
 }
 
 type fakeFetcher struct {
 	channel string
 	items   []Item
+	rand    *rand.Rand
+}
+
+func (f *fakeFetcher) String() string {
+	return f.channel
 }
 
 var FakeDuplicates bool
 
+// FakeSeed seeds the fetch delays of fake fetchers created after it is
+// set. Each fetcher mixes in its domain and keeps its own source, so a
+// feed gets the same delays on every run with the same seed, however
+// the goroutines happen to be scheduled.
+var FakeSeed int64
+
 func (f *fakeFetcher) Fetch() (items []Item, next time.Time, err error) {
 	now := time.Now()
-	next = now.Add(time.Duration(rand.Intn(5)) * 500 * time.Millisecond)
+	next = now.Add(time.Duration(f.rand.Intn(5)) * 500 * time.Millisecond)
 	item := Item{
-		Channel: f.channel,
-		Title:   fmt.Sprintf("Item %d", len(f.items)),
+		Channel:   f.channel,
+		Title:     fmt.Sprintf("Item %d", len(f.items)),
+		Published: now,
 	}
 	item.GUID = item.Channel + "/" + item.Title
 	f.items = append(f.items, item)
@@ -0,0 +1,83 @@
+package main
+
+// Filter wraps s and only passes on the items match accepts, so a
+// consumer can subscribe to, say, just the items mentioning Go:
+//
+//	match, _ := CompileFilter(`title contains "Go"`)
+//	goOnly := Filter(merged, match)
+//
+// match runs on Filter's own goroutine and should be quick. Closing
+// the filter closes s. If s ends on its own, the filter ends too once
+// the item it holds, if any, is delivered, and Close returns s's error.
+func Filter(s Subscription, match func(Item) bool) Subscription {
+	f := &filtered{
+		sub:     s,
+		match:   match,
+		updates: make(chan Item),
+		closing: make(chan chan error),
+		done:    make(chan struct{}),
+	}
+	go f.loop()
+	return f
+}
+
+type filtered struct {
+	sub     Subscription
+	match   func(Item) bool
+	updates chan Item
+	closing chan chan error
+	done    chan struct{} // closed when loop returns
+	err     error         // from closing sub, set before done
+}
+
+func (f *filtered) Updates() <-chan Item {
+	return f.updates
+}
+
+func (f *filtered) Close() error {
+	errc := make(chan error)
+	select {
+	case f.closing <- errc:
+		return <-errc
+	case <-f.done:
+		return f.err
+	}
+}
+
+func (f *filtered) loop() {
+	defer close(f.done)
+	stop := func() {
+		f.err = f.sub.Close()
+		close(f.updates)
+	}
+
+	received := f.sub.Updates()
+	var first Item
+	var updates chan Item // non-nil while first waits to be delivered
+
+	for {
+		if received == nil && updates == nil {
+			stop() // s ended and everything from it was delivered
+			return
+		}
+
+		select {
+		case errc := <-f.closing:
+			stop()
+			errc <- f.err
+			return
+		case it, ok := <-received:
+			if !ok {
+				received = nil
+				break
+			}
+			if f.match(it) {
+				first, updates = it, f.updates
+				received = nil // hold off until first is delivered
+			}
+		case updates <- first:
+			updates = nil
+			received = f.sub.Updates()
+		}
+	}
+}
@@ -0,0 +1,249 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// CompileFilter compiles a filter expression into a predicate on Items,
+// so routing rules can be written in configuration instead of Go:
+//
+//	channel == "security" && title =~ "CVE-\\d+"
+//	tag == "work" || !(title contains "sponsored")
+//
+// Fields are title, channel, guid and tag; the last matches if any of
+// the item's tags does. Operators are == and != for equality, =~ and !~
+// for regular expressions, and contains for substrings. Comparisons are
+// combined with &&, || and !, and grouped with parentheses. Values are
+// double-quoted Go strings. Expressions are checked, and regular
+// expressions compiled, once, here; evaluating one cannot fail.
+func CompileFilter(expr string) (func(Item) bool, error) {
+	p := &filterParser{lex: filterLexer{src: expr}}
+	p.next()
+	f, err := p.or()
+	if err == nil && (p.err != nil || p.tok.kind != tokEOF) {
+		err = p.errorf("unexpected %s", p.tok)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("filter %q: %v", expr, err)
+	}
+	return f, nil
+}
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokIdent
+	tokString
+	tokOp // == != =~ !~ && || ! ( )
+)
+
+type token struct {
+	kind tokenKind
+	text string // unquoted, for strings
+	pos  int
+}
+
+func (t token) String() string {
+	switch t.kind {
+	case tokEOF:
+		return "end of expression"
+	case tokString:
+		return strconv.Quote(t.text)
+	}
+	return t.text
+}
+
+type filterLexer struct {
+	src string
+	pos int
+}
+
+func (l *filterLexer) next() (token, error) {
+	for l.pos < len(l.src) && (l.src[l.pos] == ' ' || l.src[l.pos] == '\t') {
+		l.pos++
+	}
+	start := l.pos
+	if l.pos == len(l.src) {
+		return token{kind: tokEOF, pos: start}, nil
+	}
+	rest := l.src[l.pos:]
+	for _, op := range []string{"==", "!=", "=~", "!~", "&&", "||", "!", "(", ")"} {
+		if strings.HasPrefix(rest, op) {
+			l.pos += len(op)
+			return token{kind: tokOp, text: op, pos: start}, nil
+		}
+	}
+	switch c := rune(rest[0]); {
+	case c == '"':
+		end := 1
+		for end < len(rest) && rest[end] != '"' {
+			if rest[end] == '\\' {
+				end++
+			}
+			end++
+		}
+		if end >= len(rest) {
+			return token{}, fmt.Errorf("unterminated string at %d", start)
+		}
+		s, err := strconv.Unquote(rest[:end+1])
+		if err != nil {
+			return token{}, fmt.Errorf("bad string at %d: %v", start, err)
+		}
+		l.pos += end + 1
+		return token{kind: tokString, text: s, pos: start}, nil
+	case unicode.IsLetter(c):
+		end := strings.IndexFunc(rest, func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_'
+		})
+		if end < 0 {
+			end = len(rest)
+		}
+		l.pos += end
+		return token{kind: tokIdent, text: rest[:end], pos: start}, nil
+	}
+	return token{}, fmt.Errorf("unexpected %q at %d", rest[0], start)
+}
+
+// filterParser is a recursive descent parser that builds the predicate
+// directly, rather than a tree to be evaluated later.
+type filterParser struct {
+	lex filterLexer
+	tok token
+	err error // from the lexer
+}
+
+func (p *filterParser) next() {
+	if p.err == nil {
+		p.tok, p.err = p.lex.next()
+	}
+}
+
+func (p *filterParser) errorf(format string, args ...any) error {
+	if p.err != nil {
+		return p.err
+	}
+	return fmt.Errorf("at %d: %s", p.tok.pos, fmt.Sprintf(format, args...))
+}
+
+func (p *filterParser) isOp(op string) bool {
+	return p.err == nil && p.tok.kind == tokOp && p.tok.text == op
+}
+
+// or := and { "||" and }
+func (p *filterParser) or() (func(Item) bool, error) {
+	f, err := p.and()
+	for err == nil && p.isOp("||") {
+		p.next()
+		var g func(Item) bool
+		if g, err = p.and(); err == nil {
+			f = orFilter(f, g)
+		}
+	}
+	return f, err
+}
+
+// and := unary { "&&" unary }
+func (p *filterParser) and() (func(Item) bool, error) {
+	f, err := p.unary()
+	for err == nil && p.isOp("&&") {
+		p.next()
+		var g func(Item) bool
+		if g, err = p.unary(); err == nil {
+			f = andFilter(f, g)
+		}
+	}
+	return f, err
+}
+
+// unary := "!" unary | "(" or ")" | comparison
+func (p *filterParser) unary() (func(Item) bool, error) {
+	switch {
+	case p.isOp("!"):
+		p.next()
+		f, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		return func(it Item) bool { return !f(it) }, nil
+	case p.isOp("("):
+		p.next()
+		f, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+		if !p.isOp(")") {
+			return nil, p.errorf("expected ), found %s", p.tok)
+		}
+		p.next()
+		return f, nil
+	}
+	return p.comparison()
+}
+
+// comparison := field op string
+func (p *filterParser) comparison() (func(Item) bool, error) {
+	if p.err != nil || p.tok.kind != tokIdent {
+		return nil, p.errorf("expected a field, found %s", p.tok)
+	}
+	field := p.tok.text
+	var values func(Item) []string
+	switch field {
+	case "title":
+		values = func(it Item) []string { return []string{it.Title} }
+	case "channel":
+		values = func(it Item) []string { return []string{it.Channel} }
+	case "guid":
+		values = func(it Item) []string { return []string{it.GUID} }
+	case "tag":
+		values = func(it Item) []string { return it.Tags }
+	default:
+		return nil, p.errorf("unknown field %q", field)
+	}
+	p.next()
+
+	op := p.tok.text
+	switch {
+	case p.err != nil, p.tok.kind == tokString,
+		op != "==" && op != "!=" && op != "=~" && op != "!~" && op != "contains":
+		return nil, p.errorf("expected an operator after %s, found %s", field, p.tok)
+	}
+	p.next()
+	if p.err != nil || p.tok.kind != tokString {
+		return nil, p.errorf("expected a string after %s, found %s", op, p.tok)
+	}
+	value, valuePos := p.tok.text, p.tok.pos
+	p.next()
+
+	var match func(string) bool
+	switch op {
+	case "==", "!=":
+		match = func(s string) bool { return s == value }
+	case "contains":
+		match = func(s string) bool { return strings.Contains(s, value) }
+	case "=~", "!~":
+		re, err := regexp.Compile(value)
+		if err != nil {
+			return nil, fmt.Errorf("at %d: %v", valuePos, err)
+		}
+		match = re.MatchString
+	}
+	f := func(it Item) bool { return slices.ContainsFunc(values(it), match) }
+	if op == "!=" || op == "!~" {
+		return func(it Item) bool { return !f(it) }, nil
+	}
+	return f, nil
+}
+
+func andFilter(f, g func(Item) bool) func(Item) bool {
+	return func(it Item) bool { return f(it) && g(it) }
+}
+
+func orFilter(f, g func(Item) bool) func(Item) bool {
+	return func(it Item) bool { return f(it) || g(it) }
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCompileFilter(t *testing.T) {
+	items := map[string]Item{
+		"cve":      {Title: "CVE-2024-1 fixed", Channel: "security", Tags: []string{"work"}},
+		"ad":       {Title: "a sponsored post", Channel: "news"},
+		"quote":    {Title: `say "hi"`, Channel: `back\slash`},
+		"plain":    {Title: "hello", Channel: "news", GUID: "g1", Tags: []string{"home", "work"}},
+		"untagged": {Title: "hello", Channel: "security"},
+	}
+	for _, tt := range []struct {
+		expr  string
+		match []string // keys of items, in any order
+	}{
+		{`channel == "security"`, []string{"cve", "untagged"}},
+		{`channel != "security"`, []string{"ad", "quote", "plain"}},
+		{`title =~ "CVE-\\d+"`, []string{"cve"}},
+		{`title !~ "^a"`, []string{"cve", "quote", "plain", "untagged"}},
+		{`title contains "sponsored"`, []string{"ad"}},
+		{`guid == "g1"`, []string{"plain"}},
+		{`tag == "work"`, []string{"cve", "plain"}},
+		{`tag != "work"`, []string{"ad", "quote", "untagged"}},
+
+		// && binds tighter than ||, and ! tighter than both
+		{`channel == "news" || channel == "security" && title == "hello"`, []string{"ad", "plain", "untagged"}},
+		{`(channel == "news" || channel == "security") && title == "hello"`, []string{"plain", "untagged"}},
+		{`!channel == "news" && title == "hello"`, []string{"untagged"}},
+		{`!(channel == "news" && title == "hello")`, []string{"cve", "ad", "quote", "untagged"}},
+		{`tag == "work" || !(title contains "sponsored")`, []string{"cve", "quote", "plain", "untagged"}},
+		{`!!(guid == "g1")`, []string{"plain"}},
+
+		// Values are Go strings: escapes are unquoted before comparing
+		{`title == "say \"hi\""`, []string{"quote"}},
+		{`channel == "back\\slash"`, []string{"quote"}},
+		{`title == "say \x22hi\x22"`, []string{"quote"}},
+		{"channel==\"news\"&&title\t==\"hello\"", []string{"plain"}},
+	} {
+		f, err := CompileFilter(tt.expr)
+		if err != nil {
+			t.Errorf("%s: %v", tt.expr, err)
+			continue
+		}
+		for key, it := range items {
+			want := false
+			for _, k := range tt.match {
+				want = want || k == key
+			}
+			if got := f(it); got != want {
+				t.Errorf("%s on %s: got %v, want %v", tt.expr, key, got, want)
+			}
+		}
+	}
+}
+
+func TestCompileFilterErrors(t *testing.T) {
+	for _, tt := range []struct {
+		expr string
+		err  string // expected in the error
+	}{
+		{``, `at 0: expected a field, found end of expression`},
+		{`author == "x"`, `at 0: unknown field "author"`},
+		{`title == "a" && Title == "b"`, `at 16: unknown field "Title"`},
+		{`title = "a"`, `unexpected '=' at 6`},
+		{`title "a"`, `at 6: expected an operator after title, found "a"`},
+		{`title has "a"`, `at 6: expected an operator after title, found has`},
+		{`title ==`, `at 8: expected a string after ==, found end of expression`},
+		{`title == channel`, `at 9: expected a string after ==, found channel`},
+		{`title == "a`, `unterminated string at 9`},
+		{`title == "a\"`, `unterminated string at 9`},
+		{`title == "\q"`, `bad string at 9`},
+		{`title =~ "("`, `at 9: error parsing regexp`},
+		{`(title == "a"`, `at 13: expected ), found end of expression`},
+		{`title == "a")`, `at 12: unexpected )`},
+		{`title == "a" "b"`, `at 13: unexpected "b"`},
+		{`title == "a" ||`, `at 15: expected a field, found end of expression`},
+		{`title == "a" & channel == "b"`, `unexpected '&' at 13`},
+		{`title == "a" # comment`, `unexpected '#' at 13`},
+	} {
+		_, err := CompileFilter(tt.expr)
+		if err == nil {
+			t.Errorf("%s: compiled, want an error with %q", tt.expr, tt.err)
+			continue
+		}
+		if !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("%s: got %q, want it to contain %q", tt.expr, err, tt.err)
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// follow runs "follow [flags] url...": it subscribes to each RSS or
+// Atom feed, merges and dedups the subscriptions, and prints items as
+// they arrive until interrupted. It returns the exit status.
+func follow(args []string) int {
+	fs := flag.NewFlagSet("follow", flag.ExitOnError)
+	asJSON := fs.Bool("json", false, "print items as JSON, one per line")
+	minPoll := fs.Duration("min-poll", time.Minute, "shortest time between fetches of a feed")
+	maxPoll := fs.Duration("max-poll", time.Hour, "longest time between fetches of a feed")
+	window := fs.Int("dedup", 10000, "how many items to remember when dropping duplicates across feeds, 0 for all")
+	fs.StringVar(&HTTPUserAgent, "user-agent", HTTPUserAgent, "User-Agent header, with a contact URL or email")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: follow [flags] url...")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return 2
+	}
+
+	var subs []Subscription
+	for _, url := range fs.Args() {
+		f, err := FetchRSS(url, *minPoll)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			for _, s := range subs {
+				s.Close()
+			}
+			return 1
+		}
+		subs = append(subs, Subscribe(&pollBounds{fetcher: f, min: *minPoll, max: *maxPoll}))
+	}
+	merged := Dedup(Merge(subs...), ByGUID, *window)
+
+	// The first Ctrl-C closes the subscriptions; stop restores the
+	// default handling, so a second one kills a shutdown that hangs.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	errc := make(chan error, 1)
+	go func() {
+		<-ctx.Done()
+		stop()
+		closeCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		errc <- CloseContext(closeCtx, merged)
+	}()
+
+	// A shutdown that times out leaves Updates open, so wait for the
+	// close as well as for the items rather than ranging over them.
+	enc := json.NewEncoder(os.Stdout)
+	updates := merged.Updates()
+	for updates != nil || errc != nil {
+		select {
+		case it, ok := <-updates:
+			if !ok {
+				updates = nil
+				break
+			}
+			if *asJSON {
+				enc.Encode(it)
+				break
+			}
+			when := "-"
+			if !it.Published.IsZero() {
+				when = it.Published.Local().Format(time.DateTime)
+			}
+			fmt.Printf("%s  %s: %s\n", when, it.Channel, it.Title)
+		case err := <-errc:
+			if err != nil {
+				fmt.Fprintln(os.Stderr, "follow:", err)
+				return 1
+			}
+			errc = nil
+		}
+	}
+	return 0
+}
+
+// pollBounds keeps the next fetch of fetcher between min and max from
+// now, whatever the feed asks for.
+type pollBounds struct {
+	fetcher  Fetcher
+	min, max time.Duration
+}
+
+func (f *pollBounds) String() string {
+	return fmt.Sprint(f.fetcher)
+}
+
+func (f *pollBounds) Fetch() (items []Item, next time.Time, err error) {
+	return f.FetchContext(context.Background())
+}
+
+func (f *pollBounds) FetchContext(ctx context.Context) (items []Item, next time.Time, err error) {
+	items, next, err = fetchContext(ctx, f.fetcher)
+	now := time.Now()
+	d := min(max(next.Sub(now), f.min), max(f.max, f.min))
+	return items, now.Add(d), err
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"context"
+	"sync"
+)
+
+// Future holds a value that will be available later, or the error
+// that kept it from being computed. It uses the same trick as
+// fetchDone in sub.loop: a channel with room for exactly one result,
+// so whoever settles it never blocks, whether or not anyone is
+// waiting yet.
+type Future[T any] struct {
+	once sync.Once
+	c    chan outcome[T]
+}
+
+type outcome[T any] struct {
+	v   T
+	err error
+}
+
+// NewFuture returns an unsettled Future.
+func NewFuture[T any]() *Future[T] {
+	return &Future[T]{c: make(chan outcome[T], 1)}
+}
+
+// Async runs f in a new goroutine and returns a Future for its result.
+func Async[T any](f func() (T, error)) *Future[T] {
+	fut := NewFuture[T]()
+	go func() {
+		v, err := f()
+		fut.settle(outcome[T]{v, err})
+	}()
+	return fut
+}
+
+// Resolve settles the future with v. Only the first Resolve or Reject
+// counts.
+func (f *Future[T]) Resolve(v T) {
+	f.settle(outcome[T]{v: v})
+}
+
+// Reject settles the future with err. Only the first Resolve or
+// Reject counts.
+func (f *Future[T]) Reject(err error) {
+	f.settle(outcome[T]{err: err})
+}
+
+func (f *Future[T]) settle(o outcome[T]) {
+	f.once.Do(func() { f.c <- o })
+}
+
+// Get waits for the future to be settled and returns its value and
+// error, or ctx's error if ctx is done first. It can be called any
+// number of times, from any number of goroutines.
+func (f *Future[T]) Get(ctx context.Context) (T, error) {
+	select {
+	case o := <-f.c:
+		f.c <- o // put it back for the next Get
+		return o.v, o.err
+	case <-ctx.Done():
+		var zero T
+		return zero, ctx.Err()
+	}
+}
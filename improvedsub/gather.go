@@ -0,0 +1,62 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// Gather runs funcs concurrently and returns their results in the
+// order of funcs. It fails fast: the first error cancels the context
+// the others run with and is returned once they have all returned.
+func Gather[T any](ctx context.Context, funcs ...func(context.Context) (T, error)) ([]T, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var once sync.Once
+	var first error
+	results := gather(ctx, funcs, func(err error) {
+		once.Do(func() {
+			first = err
+			cancel()
+		})
+	})
+	if first != nil {
+		return nil, first
+	}
+	return results, nil
+}
+
+// GatherAll is like Gather, but lets every func finish whatever the
+// others do. Failed funcs leave the zero value in their place, and
+// their errors are joined into the one returned.
+func GatherAll[T any](ctx context.Context, funcs ...func(context.Context) (T, error)) ([]T, error) {
+	var mu sync.Mutex
+	var errs []error
+	results := gather(ctx, funcs, func(err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		errs = append(errs, err)
+	})
+	return results, errors.Join(errs...)
+}
+
+// gather runs funcs, passing their errors to failed as they happen.
+func gather[T any](ctx context.Context, funcs []func(context.Context) (T, error), failed func(error)) []T {
+	results := make([]T, len(funcs))
+	var wg sync.WaitGroup
+	for i, f := range funcs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			v, err := f(ctx)
+			if err != nil {
+				failed(err)
+				return
+			}
+			results[i] = v
+		}()
+	}
+	wg.Wait()
+	return results
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// MergeGroup merges subs like Merge, but ties them together the way
+// errgroup ties goroutines: when one child ends with an error, the
+// rest are closed too and the merged stream ends. Cancelling ctx does
+// the same. Close returns the errors of all children, joined.
+//
+// A child from Subscribe never ends on its own, it retries failed
+// fetches; to bring the group down on a fetch error, build the group
+// first with NewGroup and subscribe to fetchers wrapped by its Watch.
+func MergeGroup(ctx context.Context, subs ...Subscription) Subscription {
+	return NewGroup(ctx, nil).Merge(subs...)
+}
+
+// MergeGroupWith is like MergeGroup, but only errors cancelOn returns
+// true for bring the group down; the others just end their own child.
+func MergeGroupWith(ctx context.Context, cancelOn func(error) bool, subs ...Subscription) Subscription {
+	return NewGroup(ctx, cancelOn).Merge(subs...)
+}
+
+// Group is the context a MergeGroup runs in. Fetchers wrapped by Watch
+// report their errors to it as they happen, so the group comes down on
+// the first fetch error cancelOn accepts, not only when a child ends.
+type Group struct {
+	ctx      context.Context
+	cancel   context.CancelFunc
+	cancelOn func(error) bool
+}
+
+// NewGroup returns a group cancelled with ctx, or by any error cancelOn
+// returns true for; a nil cancelOn accepts every error.
+func NewGroup(ctx context.Context, cancelOn func(error) bool) *Group {
+	if cancelOn == nil {
+		cancelOn = func(error) bool { return true }
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	return &Group{ctx: ctx, cancel: cancel, cancelOn: cancelOn}
+}
+
+// fail cancels the group if err is one that brings it down.
+func (g *Group) fail(err error) {
+	if err != nil && !errors.Is(err, ErrRateLimited) && g.cancelOn(err) {
+		g.cancel()
+	}
+}
+
+// Watch returns a Fetcher that reports every failed fetch of f to g.
+// Being rate limited is not a failure.
+func (g *Group) Watch(f Fetcher) Fetcher {
+	return &watchedFetcher{fetcher: f, group: g}
+}
+
+type watchedFetcher struct {
+	fetcher Fetcher
+	group   *Group
+}
+
+func (f *watchedFetcher) Fetch() (items []Item, next time.Time, err error) {
+	return f.FetchContext(context.Background())
+}
+
+func (f *watchedFetcher) FetchContext(ctx context.Context) (items []Item, next time.Time, err error) {
+	items, next, err = fetchContext(ctx, f.fetcher)
+	if ctx.Err() == nil { // a cancelled fetch is not the fetcher's fault
+		f.group.fail(err)
+	}
+	return items, next, err
+}
+
+// Merge merges subs into the group's stream. It should be called once.
+func (g *Group) Merge(subs ...Subscription) Subscription {
+	m := &mergeGroup{
+		updates: make(chan Item),
+		cancel:  g.cancel,
+		done:    make(chan struct{}),
+	}
+
+	var wg sync.WaitGroup
+	errs := make([]error, len(subs))
+	for i, s := range subs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = m.forward(g.ctx, s)
+			g.fail(errs[i])
+		}()
+	}
+	go func() {
+		wg.Wait()
+		m.err = errors.Join(errs...)
+		close(m.updates)
+		close(m.done)
+	}()
+	return m
+}
+
+type mergeGroup struct {
+	updates chan Item
+	cancel  context.CancelFunc
+	done    chan struct{} // closed once every child has been closed
+	err     error         // set before done is closed
+}
+
+func (m *mergeGroup) Updates() <-chan Item {
+	return m.updates
+}
+
+func (m *mergeGroup) Close() error {
+	m.cancel()
+	<-m.done
+	return m.err
+}
+
+// forward sends the items of s on until s ends or ctx is done, then
+// closes s and returns its error.
+func (m *mergeGroup) forward(ctx context.Context, s Subscription) error {
+	for {
+		select {
+		case it, ok := <-s.Updates():
+			if !ok {
+				return s.Close()
+			}
+			select {
+			case m.updates <- it:
+			case <-ctx.Done():
+				return s.Close()
+			}
+		case <-ctx.Done():
+			return s.Close()
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestGroupCancelsOnAWatchedFetchError(t *testing.T) {
+	AssertNoLeaks(t, func() {
+		clock := NewFakeClock(time.Now())
+		failed := errors.New("feed is gone")
+		bad := NewScriptedFetcher(clock, Response{Err: failed})
+		good := NewScriptedFetcher(clock, Response{Items: []Item{{GUID: "a"}}, Next: time.Hour})
+
+		g := NewGroup(context.Background(), nil)
+		m := g.Merge(
+			SubscribeClock(g.Watch(bad), clock), // retries the error forever
+			SubscribeClock(g.Watch(good), clock))
+
+		done := make(chan struct{})
+		go func() {
+			for range m.Updates() {
+			}
+			close(done)
+		}()
+		select {
+		case <-done:
+		case <-time.After(5 * time.Second):
+			t.Fatal("the group did not end on a fetch error")
+		}
+		if err := m.Close(); !errors.Is(err, failed) {
+			t.Errorf("Close = %v; want it to include %v", err, failed)
+		}
+	})
+}
+
+func TestGroupIgnoresErrorsCancelOnRejects(t *testing.T) {
+	AssertNoLeaks(t, func() {
+		clock := NewFakeClock(time.Now())
+		f := NewScriptedFetcher(clock,
+			Response{Err: errors.New("transient")},
+			Response{Items: []Item{{GUID: "a"}}, Next: time.Hour})
+
+		g := NewGroup(context.Background(), func(error) bool { return false })
+		m := g.Merge(SubscribeClock(g.Watch(f), clock))
+		defer m.Close()
+		for len(f.Calls()) == 0 {
+			time.Sleep(time.Millisecond)
+		}
+		clock.Advance(10 * time.Second) // the loop's retry delay
+		select {
+		case it, ok := <-m.Updates():
+			if !ok || it.GUID != "a" {
+				t.Fatalf("got %v, %v; want item a", it, ok)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("no item after the retry")
+		}
+	})
+}
@@ -0,0 +1,268 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// JSONFeed describes how to read a feed out of an arbitrary JSON API.
+// Paths use a small JSONPath subset: an optional leading "$", object
+// keys separated by dots and array indices in brackets, as in
+// "$.data.children" or "media[0].url".
+type JSONFeed struct {
+	URL        string   `json:"url"`
+	Channel    string   `json:"channel,omitempty"`     // defaults to URL
+	Items      string   `json:"items"`                 // path to the array of entries
+	Title      string   `json:"title"`                 // path to the title, within an entry
+	ID         string   `json:"id,omitempty"`          // path to a stable id, within an entry
+	Published  string   `json:"published,omitempty"`   // path to the publish time, within an entry
+	TimeLayout string   `json:"time_layout,omitempty"` // time.Parse layout or "unix", defaults to RFC 3339
+	Interval   string   `json:"interval,omitempty"`    // time between fetches, defaults to 1m
+	LongPoll   string   `json:"long_poll,omitempty"`   // longest the server may hold a request, see below
+	UserAgent  string   `json:"user_agent,omitempty"`  // defaults to HTTPUserAgent
+	Tags       []string `json:"tags,omitempty"`        // added to every item
+}
+
+// A feed with LongPoll set is served by an endpoint that holds each
+// request open until it has new entries. Its fetcher asks again as
+// soon as a response arrives, ignoring Interval, and gives up on a
+// request after LongPoll. Giving up, like a 204 No Content or an empty
+// list of entries, is a normal fetch that found nothing new.
+
+// LoadJSONFeeds reads a JSON array of JSONFeed definitions, so new
+// feeds can be added through configuration alone.
+func LoadJSONFeeds(r io.Reader) ([]JSONFeed, error) {
+	var feeds []JSONFeed
+	if err := json.NewDecoder(r).Decode(&feeds); err != nil {
+		return nil, err
+	}
+	return feeds, nil
+}
+
+// FetchJSON returns a Fetcher for feed, or an error if it is not
+// a valid definition.
+func FetchJSON(feed JSONFeed) (Fetcher, error) {
+	if feed.URL == "" || feed.Items == "" || feed.Title == "" {
+		return nil, errors.New("json feed: url, items and title are required")
+	}
+	interval := time.Minute
+	if feed.Interval != "" {
+		d, err := time.ParseDuration(feed.Interval)
+		if err != nil {
+			return nil, fmt.Errorf("json feed %s: %v", feed.URL, err)
+		}
+		interval = d
+	}
+	var longPoll time.Duration
+	if feed.LongPoll != "" {
+		d, err := time.ParseDuration(feed.LongPoll)
+		if err != nil {
+			return nil, fmt.Errorf("json feed %s: %v", feed.URL, err)
+		}
+		longPoll = d
+	}
+	client := &http.Client{Timeout: 30 * time.Second}
+	if longPoll > 0 {
+		client.Timeout = 0 // the request context enforces LongPoll
+	}
+	if feed.UserAgent == "" {
+		feed.UserAgent = HTTPUserAgent
+	}
+	if err := checkUserAgent(feed.UserAgent, feed.URL); err != nil {
+		return nil, fmt.Errorf("json feed %s: %w", feed.URL, err)
+	}
+	if feed.Channel == "" {
+		feed.Channel = feed.URL
+	}
+	feed.Tags = slices.Clip(feed.Tags) // shared by all items
+	return &jsonFetcher{
+		feed:     feed,
+		interval: interval,
+		longPoll: longPoll,
+		client:   client,
+		limits:   HTTPLimits,
+	}, nil
+}
+
+type jsonFetcher struct {
+	feed     JSONFeed
+	interval time.Duration
+	longPoll time.Duration // 0 unless long polling
+	client   *http.Client
+	limits   *RateLimits
+}
+
+func (f *jsonFetcher) String() string {
+	return f.feed.URL
+}
+
+func (f *jsonFetcher) Fetch() (items []Item, next time.Time, err error) {
+	return f.FetchContext(context.Background())
+}
+
+func (f *jsonFetcher) FetchContext(ctx context.Context) (items []Item, next time.Time, err error) {
+	now := time.Now()
+	next = now.Add(f.interval)
+	if f.longPoll > 0 {
+		parent := ctx
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, f.longPoll)
+		defer cancel()
+		defer func() {
+			if err == nil {
+				next = time.Now() // ask again right away
+			} else if ctx.Err() != nil && parent.Err() == nil {
+				items, next, err = nil, time.Now(), nil // held past LongPoll: nothing new
+			}
+		}()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, f.feed.URL, nil)
+	if err != nil {
+		return nil, next, err
+	}
+	if f.feed.UserAgent != "" {
+		req.Header.Set("User-Agent", f.feed.UserAgent)
+	}
+	if wait := f.limits.Take(req.URL.Host); wait > 0 {
+		return nil, now.Add(wait), fmt.Errorf("json feed %s: %w", f.feed.URL, ErrRateLimited)
+	}
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return nil, next, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNoContent {
+		return nil, next, nil
+	}
+	if resp.StatusCode == http.StatusTooManyRequests {
+		return nil, retryAfter(resp, next), fmt.Errorf("json feed %s: %w", f.feed.URL, ErrRateLimited)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, next, fmt.Errorf("json feed %s: %s", f.feed.URL, resp.Status)
+	}
+
+	var doc any
+	dec := json.NewDecoder(resp.Body)
+	dec.UseNumber()
+	if err := dec.Decode(&doc); err != nil {
+		return nil, next, err
+	}
+	entries, err := f.entries(doc)
+	if err != nil {
+		return nil, next, err
+	}
+	for _, e := range entries {
+		item, err := f.item(e)
+		if err != nil {
+			return nil, next, err
+		}
+		items = append(items, item)
+	}
+	return items, next, nil
+}
+
+func (f *jsonFetcher) entries(doc any) ([]any, error) {
+	v, err := lookupJSON(doc, f.feed.Items)
+	if err != nil {
+		return nil, err
+	}
+	entries, ok := v.([]any)
+	if !ok {
+		return nil, fmt.Errorf("json feed %s: %s is not an array", f.feed.URL, f.feed.Items)
+	}
+	return entries, nil
+}
+
+func (f *jsonFetcher) item(entry any) (Item, error) {
+	title, err := lookupJSON(entry, f.feed.Title)
+	if err != nil {
+		return Item{}, err
+	}
+	item := Item{
+		Channel: f.feed.Channel,
+		Title:   fmt.Sprint(title),
+		Tags:    f.feed.Tags,
+	}
+	if f.feed.ID != "" {
+		id, err := lookupJSON(entry, f.feed.ID)
+		if err != nil {
+			return Item{}, err
+		}
+		item.GUID = item.Channel + "/" + fmt.Sprint(id)
+	} else {
+		item.GUID = item.Channel + "/" + item.Title
+	}
+	if f.feed.Published != "" {
+		v, err := lookupJSON(entry, f.feed.Published)
+		if err != nil {
+			return Item{}, err
+		}
+		if item.Published, err = parseJSONTime(v, f.feed.TimeLayout); err != nil {
+			return Item{}, err
+		}
+	}
+	return item, nil
+}
+
+func parseJSONTime(v any, layout string) (time.Time, error) {
+	s := fmt.Sprint(v)
+	switch layout {
+	case "unix":
+		sec, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return time.Time{}, err
+		}
+		return time.Unix(0, int64(sec*float64(time.Second))), nil
+	case "":
+		layout = time.RFC3339
+	}
+	return time.Parse(layout, s)
+}
+
+// lookupJSON follows path through a value decoded by encoding/json.
+func lookupJSON(v any, path string) (any, error) {
+	rest := strings.TrimPrefix(path, "$")
+	for rest != "" {
+		switch rest[0] {
+		case '.':
+			rest = rest[1:]
+		case '[':
+			end := strings.IndexByte(rest, ']')
+			if end < 0 {
+				return nil, fmt.Errorf("json path %q: missing ]", path)
+			}
+			i, err := strconv.Atoi(rest[1:end])
+			if err != nil {
+				return nil, fmt.Errorf("json path %q: bad index %q", path, rest[1:end])
+			}
+			a, ok := v.([]any)
+			if !ok || i < 0 || i >= len(a) {
+				return nil, fmt.Errorf("json path %q: no element %d", path, i)
+			}
+			v, rest = a[i], rest[end+1:]
+		default:
+			end := strings.IndexAny(rest, ".[")
+			if end < 0 {
+				end = len(rest)
+			}
+			o, ok := v.(map[string]any)
+			if !ok {
+				return nil, fmt.Errorf("json path %q: not an object at %q", path, rest[:end])
+			}
+			if v, ok = o[rest[:end]]; !ok {
+				return nil, fmt.Errorf("json path %q: no field %q", path, rest[:end])
+			}
+			rest = rest[end:]
+		}
+	}
+	return v, nil
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// SourcedItem is an Item together with the subscription that produced
+// it and the label that subscription was given at merge time.
+type SourcedItem struct {
+	Label string
+	Sub   Subscription
+	Item
+}
+
+// SourcedSubscription is a Subscription whose items carry their source.
+type SourcedSubscription interface {
+	Updates() <-chan SourcedItem // stream of SourcedItems
+	Close() error                // close the stream
+}
+
+// MergeLabeled is like Merge, but takes each subscription under a label
+// and keeps track of where every item came from, so items can be routed
+// downstream by source rather than by the Channel string alone.
+func MergeLabeled(subs map[string]Subscription) SourcedSubscription {
+	m := &labeledMerge{
+		subs:    subs,
+		updates: make(chan SourcedItem),
+		quit:    make(chan struct{}),
+		errs:    make(chan error),
+	}
+
+	m.live.Store(int64(len(subs)))
+	if len(subs) == 0 {
+		m.end()
+	}
+	for label, sub := range subs {
+		go func(label string, s Subscription) {
+			for {
+				it := SourcedItem{Label: label, Sub: s}
+				var ok bool
+				select {
+				case it.Item, ok = <-s.Updates():
+					if !ok {
+						m.end()
+						<-m.quit // Close still closes s and waits for us
+						m.errs <- s.Close()
+						return
+					}
+				case <-m.quit:
+					m.errs <- s.Close()
+					return
+				}
+
+				select {
+				case m.updates <- it:
+				case <-m.quit:
+					m.errs <- s.Close()
+					return
+				}
+			}
+		}(label, sub)
+	}
+
+	return m
+}
+
+type labeledMerge struct {
+	subs    map[string]Subscription
+	updates chan SourcedItem
+	quit    chan struct{}
+	errs    chan error
+	once    sync.Once
+	err     error        // from the first Close
+	live    atomic.Int64 // subscriptions that have not ended
+	ended   sync.Once    // closes updates
+}
+
+func (m *labeledMerge) Updates() <-chan SourcedItem {
+	return m.updates
+}
+
+// Close is idempotent, like Merge's.
+func (m *labeledMerge) Close() error {
+	m.once.Do(func() {
+		close(m.quit)
+		for range m.subs {
+			if e := <-m.errs; e != nil {
+				m.err = e
+			}
+		}
+		m.ended.Do(func() { close(m.updates) })
+	})
+	return m.err
+}
+
+// end is called by each forwarder whose subscription ended on its own.
+// Once all of them have, nothing more can be sent, and Updates is
+// closed so that ranging over it stops.
+func (m *labeledMerge) end() {
+	if m.live.Add(-1) <= 0 {
+		m.ended.Do(func() { close(m.updates) })
+	}
+}
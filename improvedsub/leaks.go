@@ -0,0 +1,67 @@
+package main
+
+import (
+	"bytes"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// Failer is the part of *testing.T that AssertNoLeaks reports to.
+type Failer interface {
+	Helper()
+	Errorf(format string, args ...any)
+}
+
+// AssertNoLeaks runs body and fails t, with their stacks, if goroutines
+// started during body are still running after it. This is what the
+// panic("Show me the stacks") at the end of main does by eye. A closed
+// subscription may take a moment to unwind, so leftovers are given a
+// second to exit before they count.
+func AssertNoLeaks(t Failer, body func()) {
+	t.Helper()
+	before := make(map[string]bool)
+	for _, g := range goroutines() {
+		before[goroutineID(g)] = true
+	}
+
+	body()
+
+	var leaked []string
+	for deadline := time.Now().Add(time.Second); ; {
+		leaked = leaked[:0]
+		for _, g := range goroutines() {
+			if !before[goroutineID(g)] {
+				leaked = append(leaked, g)
+			}
+		}
+		if len(leaked) == 0 || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if len(leaked) > 0 {
+		t.Errorf("%d goroutines leaked:\n\n%s", len(leaked), strings.Join(leaked, "\n\n"))
+	}
+}
+
+// goroutines returns the stack of every goroutine but the caller's.
+func goroutines() []string {
+	buf := make([]byte, 1<<16)
+	for {
+		n := runtime.Stack(buf, true)
+		if n < len(buf) {
+			buf = buf[:n]
+			break
+		}
+		buf = make([]byte, 2*len(buf))
+	}
+	stacks := strings.Split(string(bytes.TrimSpace(buf)), "\n\n")
+	return stacks[1:] // the first is the caller
+}
+
+// goroutineID returns the "goroutine N" prefix of a stack.
+func goroutineID(stack string) string {
+	id, _, _ := strings.Cut(stack, " [")
+	return id
+}
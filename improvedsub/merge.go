@@ -1,10 +1,19 @@
 package main
 
+import (
+	"sync"
+	"sync/atomic"
+)
+
 type merge struct {
 	subs    []Subscription
 	updates chan Item
 	quit    chan struct{}
 	errs    chan error
+	once    sync.Once
+	err     error        // from the first Close
+	live    atomic.Int64 // subscriptions that have not ended
+	ended   sync.Once    // closes updates
 }
 
 func Merge(subs ...Subscription) Subscription {
@@ -15,12 +24,23 @@ func Merge(subs ...Subscription) Subscription {
 		errs:    make(chan error),
 	}
 
+	m.live.Store(int64(len(subs)))
+	if len(subs) == 0 {
+		m.end()
+	}
 	for _, sub := range subs {
 		go func(s Subscription) {
 			for {
 				var it Item
+				var ok bool
 				select {
-				case it = <-s.Updates():
+				case it, ok = <-s.Updates():
+					if !ok {
+						m.end()
+						<-m.quit // Close still closes s and waits for us
+						m.errs <- s.Close()
+						return
+					}
 				case <-m.quit:
 					m.errs <- s.Close()
 					return
@@ -43,13 +63,27 @@ func (m *merge) Updates() <-chan Item {
 	return m.updates
 }
 
-func (m *merge) Close() (err error) {
-	close(m.quit)
-	for _ = range m.subs {
-		if e := <-m.errs; e != nil {
-			err = e
+// Close closes every merged subscription. Only the first call does
+// the work; later ones, from any goroutine, wait for it and return the
+// same error.
+func (m *merge) Close() error {
+	m.once.Do(func() {
+		close(m.quit)
+		for _ = range m.subs {
+			if e := <-m.errs; e != nil {
+				m.err = e
+			}
 		}
+		m.ended.Do(func() { close(m.updates) })
+	})
+	return m.err
+}
+
+// end is called by each forwarder whose subscription ended on its own.
+// Once all of them have, nothing more can be sent, and Updates is
+// closed so that ranging over it stops.
+func (m *merge) end() {
+	if m.live.Add(-1) <= 0 {
+		m.ended.Do(func() { close(m.updates) })
 	}
-	close(m.updates)
-	return
 }
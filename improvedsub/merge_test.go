@@ -0,0 +1,105 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMergeEndsWithItsSubscriptions(t *testing.T) {
+	AssertNoLeaks(t, func() {
+		m := Merge(
+			SubscribeFor(Fetch("a.example"), 50*time.Millisecond),
+			SubscribeFor(Fetch("b.example"), 100*time.Millisecond))
+		var n int
+		timeout := time.After(5 * time.Second)
+	loop:
+		for {
+			select {
+			case it, ok := <-m.Updates():
+				if !ok {
+					break loop
+				}
+				if it.GUID == "" {
+					t.Fatal("got a zero Item from a subscription that ended")
+				}
+				n++
+			case <-timeout:
+				t.Fatal("Updates still open after every subscription ended")
+			}
+		}
+		if n == 0 {
+			t.Error("got no items")
+		}
+		if err := m.Close(); err != nil {
+			t.Errorf("Close: %v", err)
+		}
+	})
+}
+
+func TestMergeLabeledEndsWithItsSubscriptions(t *testing.T) {
+	AssertNoLeaks(t, func() {
+		m := MergeLabeled(map[string]Subscription{
+			"a": SubscribeFor(Fetch("a.example"), 50*time.Millisecond),
+			"b": SubscribeFor(Fetch("b.example"), 100*time.Millisecond),
+		})
+		timeout := time.After(5 * time.Second)
+		for {
+			select {
+			case it, ok := <-m.Updates():
+				if !ok {
+					if err := m.Close(); err != nil {
+						t.Errorf("Close: %v", err)
+					}
+					return
+				}
+				if it.GUID == "" {
+					t.Fatalf("got a zero Item from %s after it ended", it.Label)
+				}
+			case <-timeout:
+				t.Fatal("Updates still open after every subscription ended")
+			}
+		}
+	})
+}
+
+func TestBufferedMergesEndWithTheirSubscriptions(t *testing.T) {
+	merges := map[string]func(a, b Subscription) Subscription{
+		"FairMerge": func(a, b Subscription) Subscription { return FairMerge(a, b) },
+		"MergeWithPriority": func(a, b Subscription) Subscription {
+			return MergeWithPriority(map[Subscription]int{a: 2, b: 1})
+		},
+		"OrderedMerge": func(a, b Subscription) Subscription { return OrderedMerge(time.Second, a, b) },
+	}
+	for name, merge := range merges {
+		t.Run(name, func(t *testing.T) {
+			AssertNoLeaks(t, func() {
+				m := merge(
+					SubscribeFor(Fetch("a.example"), 50*time.Millisecond),
+					SubscribeFor(Fetch("b.example"), 100*time.Millisecond))
+				var n int
+				timeout := time.After(5 * time.Second)
+			loop:
+				for {
+					select {
+					case it, ok := <-m.Updates():
+						if !ok {
+							break loop
+						}
+						if it.GUID == "" {
+							t.Fatal("got a zero Item from a subscription that ended")
+						}
+						n++
+					case <-timeout:
+						t.Fatal("Updates still open after every subscription ended")
+					}
+				}
+				if n == 0 {
+					t.Error("got no items")
+				}
+				if err := m.Close(); err != nil {
+					t.Errorf("Close: %v", err)
+				}
+			})
+		})
+	}
+}
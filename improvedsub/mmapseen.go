@@ -0,0 +1,293 @@
+//go:build linux || darwin
+
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"os"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// The file is a header followed by an open-addressed hash table of
+// slots. A slot holds a key's 64-bit fingerprint, zero if empty, and
+// the unix time it was added.
+const (
+	mmapMagic     = "SEENSET1"
+	mmapHeader    = 24 // magic, slot count, used slots
+	mmapSlot      = 16
+	mmapMinSlots  = 1024
+	mmapLoadLimit = 2  // compact when more than 1/2 of the slots are used
+	mmapRetry     = 64 // adds between attempts after a failed compaction
+)
+
+// MmapSeen is a SeenStore kept in a memory-mapped file, so dedup
+// survives restarts without a database. Only fingerprints are stored,
+// 16 bytes a key, and lookups touch just the pages they probe. Keys
+// older than maxAge are dropped whenever the table fills up and is
+// compacted into a new file, which keeps it from growing forever; a
+// maxAge of zero keeps every key. Writes go to the page cache, so they
+// survive the process crashing but not necessarily the machine. A
+// compaction that fails, on a full disk say, is tried again every few
+// adds; meanwhile new keys that find no room are let through unrecorded
+// rather than dropped.
+type MmapSeen struct {
+	mu      sync.Mutex
+	path    string
+	maxAge  time.Duration
+	f       *os.File
+	data    []byte // nil once closed, or if remapping failed
+	slots   uint64
+	used    uint64
+	closed  bool
+	err     error // from the last compaction, if it failed
+	retryIn int   // adds until the next attempt after a failure
+}
+
+// OpenMmapSeen opens the seen set at path, creating it if needed.
+func OpenMmapSeen(path string, maxAge time.Duration) (*MmapSeen, error) {
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		if err := createMmapFile(path, mmapMinSlots, nil); err != nil {
+			return nil, err
+		}
+	}
+	s := &MmapSeen{path: path, maxAge: maxAge}
+	if err := s.mapFile(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *MmapSeen) Add(key string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return true // let items through rather than drop them
+	}
+	now := time.Now().Unix()
+	if s.data == nil && !s.retry(s.mapFile) {
+		return true // the file is still not mapped
+	}
+	fp := fingerprint(key)
+	i := s.find(fp)
+	if f, added := s.slot(i); f == fp {
+		if !s.expired(added, now) {
+			return false
+		}
+		s.setSlot(i, fp, now)
+		return true
+	}
+	if (s.used+1)*mmapLoadLimit > s.slots && s.retry(func() error { return s.compact(now) }) {
+		i = s.find(fp)
+	}
+	if s.data == nil {
+		return true // the old file is gone and the new one failed to map
+	}
+	if s.used+1 >= s.slots {
+		return true // full after a failed compaction
+	}
+	s.setSlot(i, fp, now)
+	s.used++
+	binary.LittleEndian.PutUint64(s.data[16:], s.used)
+	return true
+}
+
+// retry runs f unless an earlier attempt failed less than mmapRetry
+// adds ago, and reports whether it ran and succeeded.
+func (s *MmapSeen) retry(f func() error) bool {
+	if s.retryIn > 0 {
+		s.retryIn--
+		return false
+	}
+	if s.err = f(); s.err != nil {
+		s.retryIn = mmapRetry
+		return false
+	}
+	return true
+}
+
+// Compact rewrites the file without expired keys. Add does this by
+// itself when the table fills up; call it from a ticker to also
+// reclaim space on sets that stopped growing.
+func (s *MmapSeen) Compact() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return os.ErrClosed
+	}
+	if s.data == nil {
+		s.err = s.mapFile()
+		if s.err != nil {
+			return s.err
+		}
+	}
+	s.err = s.compact(time.Now().Unix())
+	s.retryIn = 0
+	return s.err
+}
+
+// Close unmaps and closes the file. It returns the error of the last
+// compaction if that failed and none has succeeded since, as Add has
+// no way to report it.
+func (s *MmapSeen) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return nil
+	}
+	s.closed = true
+	var err error
+	if s.data != nil {
+		err = s.unmap()
+	}
+	if s.err != nil {
+		err = s.err
+	}
+	return err
+}
+
+// find returns the slot holding fp, or the empty slot where it goes.
+func (s *MmapSeen) find(fp uint64) uint64 {
+	i := fp & (s.slots - 1)
+	for {
+		if f, _ := s.slot(i); f == fp || f == 0 {
+			return i
+		}
+		i = (i + 1) & (s.slots - 1)
+	}
+}
+
+func (s *MmapSeen) slot(i uint64) (fp uint64, added int64) {
+	b := s.data[mmapHeader+i*mmapSlot:]
+	return binary.LittleEndian.Uint64(b), int64(binary.LittleEndian.Uint64(b[8:]))
+}
+
+func (s *MmapSeen) setSlot(i, fp uint64, added int64) {
+	b := s.data[mmapHeader+i*mmapSlot:]
+	binary.LittleEndian.PutUint64(b[8:], uint64(added))
+	binary.LittleEndian.PutUint64(b, fp)
+}
+
+func (s *MmapSeen) expired(added, now int64) bool {
+	return s.maxAge > 0 && now-added > int64(s.maxAge/time.Second)
+}
+
+// compact copies the live slots into a new file sized for them, then
+// swaps it in with a rename so a crash leaves one file or the other.
+func (s *MmapSeen) compact(now int64) error {
+	type entry struct {
+		fp    uint64
+		added int64
+	}
+	var live []entry
+	for i := uint64(0); i < s.slots; i++ {
+		if fp, added := s.slot(i); fp != 0 && !s.expired(added, now) {
+			live = append(live, entry{fp, added})
+		}
+	}
+	slots := uint64(mmapMinSlots)
+	for uint64(len(live)+1)*mmapLoadLimit*2 > slots {
+		slots *= 2
+	}
+	tmp := s.path + ".tmp"
+	err := createMmapFile(tmp, slots, func(data []byte) uint64 {
+		for _, e := range live {
+			i := e.fp & (slots - 1)
+			for binary.LittleEndian.Uint64(data[mmapHeader+i*mmapSlot:]) != 0 {
+				i = (i + 1) & (slots - 1)
+			}
+			b := data[mmapHeader+i*mmapSlot:]
+			binary.LittleEndian.PutUint64(b, e.fp)
+			binary.LittleEndian.PutUint64(b[8:], uint64(e.added))
+		}
+		return uint64(len(live))
+	})
+	if err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, s.path); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	if err := s.unmap(); err != nil {
+		return err
+	}
+	return s.mapFile()
+}
+
+// createMmapFile writes an empty table of slots to path, letting fill
+// populate it and return how many slots it used.
+func createMmapFile(path string, slots uint64, fill func([]byte) uint64) error {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0o644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	size := int(mmapHeader + slots*mmapSlot)
+	if err := f.Truncate(int64(size)); err != nil {
+		return err
+	}
+	data, err := syscall.Mmap(int(f.Fd()), 0, size, syscall.PROT_READ|syscall.PROT_WRITE, syscall.MAP_SHARED)
+	if err != nil {
+		return err
+	}
+	var used uint64
+	if fill != nil {
+		used = fill(data)
+	}
+	copy(data, mmapMagic)
+	binary.LittleEndian.PutUint64(data[8:], slots)
+	binary.LittleEndian.PutUint64(data[16:], used)
+	if err := syscall.Munmap(data); err != nil {
+		return err
+	}
+	return f.Sync()
+}
+
+func (s *MmapSeen) mapFile() error {
+	f, err := os.OpenFile(s.path, os.O_RDWR, 0)
+	if err != nil {
+		return err
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	data, err := syscall.Mmap(int(f.Fd()), 0, int(fi.Size()), syscall.PROT_READ|syscall.PROT_WRITE, syscall.MAP_SHARED)
+	if err != nil {
+		f.Close()
+		return err
+	}
+	if len(data) < mmapHeader || !bytes.Equal(data[:8], []byte(mmapMagic)) {
+		syscall.Munmap(data)
+		f.Close()
+		return fmt.Errorf("seen set %s: not a seen set file", s.path)
+	}
+	slots := binary.LittleEndian.Uint64(data[8:])
+	if slots == 0 || slots&(slots-1) != 0 || uint64(len(data)) != mmapHeader+slots*mmapSlot {
+		syscall.Munmap(data)
+		f.Close()
+		return fmt.Errorf("seen set %s: corrupt header", s.path)
+	}
+	s.f, s.data, s.slots = f, data, slots
+	s.used = binary.LittleEndian.Uint64(data[16:])
+	return nil
+}
+
+func (s *MmapSeen) unmap() error {
+	err := syscall.Munmap(s.data)
+	if cerr := s.f.Close(); err == nil {
+		err = cerr
+	}
+	s.f, s.data = nil, nil
+	return err
+}
+
+// fingerprint hashes key to a non-zero value, zero marking empty slots.
+func fingerprint(key string) uint64 {
+	return max(fnv64a(key), 1)
+}
@@ -0,0 +1,56 @@
+//go:build linux || darwin
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestMmapSeenRetriesFailedCompaction(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "seen")
+	s, err := OpenMmapSeen(path, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	size := func() int64 {
+		t.Helper()
+		fi, err := os.Stat(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return fi.Size()
+	}
+	before := size()
+
+	// A directory where the compacted file goes makes compaction fail
+	if err := os.Mkdir(path+".tmp", 0o755); err != nil {
+		t.Fatal(err)
+	}
+	n := mmapMinSlots/mmapLoadLimit + 1 // past the load limit
+	for i := range n {
+		s.Add(fmt.Sprint(i))
+	}
+	if size() != before {
+		t.Fatal("compacted with the way blocked")
+	}
+
+	os.Remove(path + ".tmp")
+	for i := range mmapRetry + 1 {
+		s.Add(fmt.Sprint(n + i))
+	}
+	if size() <= before {
+		t.Fatal("compaction not retried once it could succeed")
+	}
+	for i := range n + mmapRetry + 1 {
+		if s.Add(fmt.Sprint(i)) {
+			t.Fatalf("key %d forgotten by the compaction", i)
+		}
+	}
+	if err := s.Close(); err != nil {
+		t.Errorf("Close = %v; want nil after a successful retry", err)
+	}
+}
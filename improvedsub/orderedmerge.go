@@ -0,0 +1,200 @@
+package main
+
+import (
+	"container/heap"
+	"time"
+)
+
+// OrderedMerge is like Merge, but delivers items in Published order.
+// Items are buffered until every open child has published something
+// newer (the low watermark), so a source that is merely slow cannot
+// cause its items to be delivered out of order. A source that stays
+// silent holds the others back for at most maxLateness after an item
+// arrived, however old its Published time; items that arrive after
+// their place in the timeline has passed are delivered as soon as
+// possible rather than dropped. At most maxOrdered items are held, and
+// once that many wait the oldest goes out without waiting further. Once every child has ended
+// and everything buffered was delivered, Updates is closed.
+func OrderedMerge(maxLateness time.Duration, subs ...Subscription) Subscription {
+	m := &orderedMerge{
+		subs:        subs,
+		maxLateness: maxLateness,
+		updates:     make(chan Item),
+		closing:     make(chan chan error),
+		received:    make(chan childItem),
+		quit:        make(chan struct{}),
+		errs:        make(chan error),
+		done:        make(chan struct{}),
+	}
+
+	for i, sub := range subs {
+		go m.forward(i, sub)
+	}
+	go m.loop()
+
+	return m
+}
+
+type orderedMerge struct {
+	subs        []Subscription
+	maxLateness time.Duration
+	updates     chan Item
+	closing     chan chan error
+	received    chan childItem // from the forwarders to loop
+	quit        chan struct{}
+	errs        chan error
+	done        chan struct{} // closed when loop returns
+	err         error         // from closing the children, set before done
+}
+
+// childItem is an Item tagged with the index of the child it came
+// from. ok is false once that child's Updates channel has been closed.
+type childItem struct {
+	source int
+	item   Item
+	ok     bool
+}
+
+func (m *orderedMerge) Updates() <-chan Item {
+	return m.updates
+}
+
+func (m *orderedMerge) Close() error {
+	errc := make(chan error)
+	select {
+	case m.closing <- errc:
+		return <-errc
+	case <-m.done:
+		return m.err
+	}
+}
+
+func (m *orderedMerge) forward(i int, s Subscription) {
+	for {
+		var ci childItem
+		select {
+		case ci.item, ci.ok = <-s.Updates():
+			ci.source = i
+		case <-m.quit:
+			m.errs <- s.Close()
+			return
+		}
+
+		select {
+		case m.received <- ci:
+		case <-m.quit:
+			m.errs <- s.Close()
+			return
+		}
+
+		if !ci.ok {
+			<-m.quit
+			m.errs <- s.Close()
+			return
+		}
+	}
+}
+
+const maxOrdered = 100
+
+func (m *orderedMerge) loop() {
+	defer close(m.done)
+	latest := make([]time.Time, len(m.subs)) // newest Published per child
+	open := make([]bool, len(m.subs))
+	for i := range open {
+		open[i] = true
+	}
+	var pending itemHeap
+	live := len(m.subs)
+	stop := func() {
+		close(m.quit)
+		for range m.subs {
+			if e := <-m.errs; e != nil {
+				m.err = e
+			}
+		}
+		close(m.updates)
+	}
+
+	for {
+		if live == 0 && len(pending) == 0 {
+			stop() // every child ended and everything was delivered
+			return
+		}
+
+		// The watermark is the oldest of the children's newest items:
+		// nothing older than that can still arrive in order.
+		var watermark time.Time
+		first := true
+		for i := range latest {
+			if open[i] && (first || latest[i].Before(watermark)) {
+				watermark = latest[i]
+				first = false
+			}
+		}
+
+		var received <-chan childItem
+		if len(pending) < maxOrdered {
+			received = m.received
+		}
+
+		var head Item
+		var updates chan Item
+		var lateness <-chan time.Time
+		if len(pending) > 0 {
+			head = pending[0].it
+			switch {
+			case first, len(pending) >= maxOrdered, !head.Published.After(watermark):
+				updates = m.updates
+			default:
+				// Published is the feed's say, often hours ago; how long
+				// head has been held is measured from when it arrived.
+				wait := time.Until(pending[0].at.Add(m.maxLateness))
+				if wait <= 0 {
+					updates = m.updates
+				} else {
+					lateness = time.After(wait)
+				}
+			}
+		}
+
+		select {
+		case errc := <-m.closing:
+			stop()
+			errc <- m.err
+			return
+		case ci := <-received:
+			if !ci.ok {
+				open[ci.source] = false
+				live--
+				break
+			}
+			if ci.item.Published.After(latest[ci.source]) {
+				latest[ci.source] = ci.item.Published
+			}
+			heap.Push(&pending, queued{ci.item, time.Now()})
+		case <-lateness:
+			// head has waited long enough, sent on the next iteration
+		case updates <- head:
+			heap.Pop(&pending)
+		}
+	}
+}
+
+// itemHeap is a min-heap of queued Items ordered by Published.
+type itemHeap []queued
+
+func (h itemHeap) Len() int           { return len(h) }
+func (h itemHeap) Less(i, j int) bool { return h[i].it.Published.Before(h[j].it.Published) }
+func (h itemHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+
+func (h *itemHeap) Push(x any) {
+	*h = append(*h, x.(queued))
+}
+
+func (h *itemHeap) Pop() any {
+	old := *h
+	it := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return it
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestOrderedMergeReordersOldItems(t *testing.T) {
+	a, b := make(chanSub), make(chanSub)
+	m := OrderedMerge(time.Second, a, b)
+	defer m.Close()
+
+	// Both were published hours ago, as feed items usually are; b's is
+	// older but arrives a little later, well within maxLateness.
+	hoursAgo := time.Now().Add(-2 * time.Hour)
+	go func() {
+		a <- Item{GUID: "a", Published: hoursAgo.Add(time.Minute)}
+		time.Sleep(50 * time.Millisecond)
+		b <- Item{GUID: "b", Published: hoursAgo}
+	}()
+
+	var got []Item
+	for range 2 {
+		select {
+		case it := <-m.Updates():
+			got = append(got, it)
+		case <-time.After(5 * time.Second):
+			t.Fatal("items not delivered")
+		}
+	}
+	ExpectOrdered(t, got, func(x, y Item) bool { return x.Published.Before(y.Published) })
+}
+
+func TestOrderedMergeBoundsWhatItHolds(t *testing.T) {
+	a, silent := make(chanSub), make(chanSub)
+	m := OrderedMerge(time.Hour, a, silent)
+	defer m.Close()
+
+	// silent never publishes, so nothing passes the watermark; only the
+	// bound lets items through before the hour is up
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		for i := range maxOrdered + 1 {
+			select {
+			case a <- Item{GUID: string(rune(i)), Published: time.Now()}:
+			case <-done:
+				return
+			}
+		}
+	}()
+	select {
+	case <-m.Updates():
+	case <-time.After(5 * time.Second):
+		t.Fatalf("nothing delivered with %d items held", maxOrdered)
+	}
+}
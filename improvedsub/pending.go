@@ -0,0 +1,53 @@
+package main
+
+import "time"
+
+// PendingAgeBuckets are the upper bounds of the age histogram in
+// PendingStats; a last, implicit bucket holds anything older.
+var PendingAgeBuckets = []time.Duration{
+	time.Second, 5 * time.Second, 30 * time.Second, time.Minute, 5 * time.Minute,
+}
+
+// PendingStats describes the items a subscription has fetched but its
+// consumer has not taken yet. A growing Oldest is an early sign of a
+// slow consumer, well before the queue is full and fetching stops.
+type PendingStats struct {
+	Count  int
+	Oldest time.Duration // age of the oldest pending item
+	Ages   []int         // items per PendingAgeBuckets bucket, plus one for older
+}
+
+// PendingReporter is implemented by the subscriptions Subscribe and
+// its variants return.
+type PendingReporter interface {
+	Pending() PendingStats
+}
+
+// newPendingStats ages the items in pending as of now, which must come
+// from the clock that stamped them.
+func newPendingStats(pending *ring[queued], now time.Time) PendingStats {
+	st := PendingStats{Count: pending.len(), Ages: make([]int, len(PendingAgeBuckets)+1)}
+	for i := range pending.len() {
+		age := now.Sub(pending.at(i).at)
+		st.Oldest = max(st.Oldest, age)
+		b := 0
+		for b < len(PendingAgeBuckets) && age > PendingAgeBuckets[b] {
+			b++
+		}
+		st.Ages[b]++
+	}
+	return st
+}
+
+// PendingByFeed collects the pending stats of every subscription in
+// subs that reports them, keyed like subs, so the oldest item of each
+// feed can be exported as a gauge.
+func PendingByFeed(subs map[string]Subscription) map[string]PendingStats {
+	stats := make(map[string]PendingStats)
+	for feed, s := range subs {
+		if r, ok := s.(PendingReporter); ok {
+			stats[feed] = r.Pending()
+		}
+	}
+	return stats
+}
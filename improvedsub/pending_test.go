@@ -0,0 +1,23 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPendingAgesOnTheSubscriptionClock(t *testing.T) {
+	AssertNoLeaks(t, func() {
+		clock := NewFakeClock(time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC))
+		f := NewScriptedFetcher(clock, Response{Items: []Item{{GUID: "a"}}, Next: time.Hour})
+		s := SubscribeClock(f, clock).(*sub)
+		defer s.Close()
+		for s.Pending().Count == 0 {
+			time.Sleep(time.Millisecond)
+		}
+
+		clock.Advance(90 * time.Second)
+		if got := s.Pending().Oldest; got != 90*time.Second {
+			t.Errorf("oldest item is %v old; want 1m30s on the fake clock", got)
+		}
+	})
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"context"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// PressureLimits are the thresholds between degradation levels. Level
+// n is reached once heap use passes Heap[n-1] bytes or the goroutine
+// count passes Goroutines[n-1], whichever gives the higher level, so
+// both lists must be increasing; either may be left empty.
+type PressureLimits struct {
+	Heap       []uint64
+	Goroutines []int
+}
+
+// PressureEvent reports a change of level and the readings behind it.
+type PressureEvent struct {
+	Level      int
+	Heap       uint64
+	Goroutines int
+}
+
+// Pressure tracks how close the process is to running out of memory
+// or drowning in goroutines, as a level from 0, all is well, up to the
+// number of limits. Expensive optional work can check Level and back
+// off as it rises: Relieve stretches polling intervals, Shed skips
+// classification and NewRelievedBroadcast shrinks replay buffers;
+// together they shed load before the process is killed for it.
+type Pressure struct {
+	limits PressureLimits
+	level  atomic.Int64
+	events chan PressureEvent
+	quit   chan struct{}
+	done   chan struct{}
+	once   sync.Once
+}
+
+// WatchPressure samples the heap and goroutines every interval, or
+// every second if interval is not positive, until Stop is called.
+func WatchPressure(limits PressureLimits, interval time.Duration) *Pressure {
+	if interval <= 0 {
+		interval = time.Second
+	}
+	p := &Pressure{
+		limits: limits,
+		events: make(chan PressureEvent, 16),
+		quit:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+	go p.loop(interval)
+	return p
+}
+
+// Level returns the current level.
+func (p *Pressure) Level() int {
+	return int(p.level.Load())
+}
+
+// Events delivers every change of level, and is closed by Stop. Events
+// nobody reads are dropped once a few have queued up.
+func (p *Pressure) Events() <-chan PressureEvent {
+	return p.events
+}
+
+// Stop stops sampling and closes Events. The level stays where it
+// was. It can be called more than once.
+func (p *Pressure) Stop() {
+	p.once.Do(func() {
+		close(p.quit)
+		<-p.done
+		close(p.events) // loop, the only sender, has returned
+	})
+}
+
+// Shrink returns n halved at each level, but at least 1.
+func (p *Pressure) Shrink(n int) int {
+	return max(n>>min(p.Level(), 30), 1)
+}
+
+func (p *Pressure) loop(interval time.Duration) {
+	defer close(p.done)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	var ms runtime.MemStats
+	for {
+		runtime.ReadMemStats(&ms)
+		e := PressureEvent{Heap: ms.HeapAlloc, Goroutines: runtime.NumGoroutine()}
+		for e.Level < len(p.limits.Heap) && e.Heap > p.limits.Heap[e.Level] {
+			e.Level++
+		}
+		for n := e.Level; n < len(p.limits.Goroutines) && e.Goroutines > p.limits.Goroutines[n]; n++ {
+			e.Level = n + 1
+		}
+		if old := p.level.Swap(int64(e.Level)); old != int64(e.Level) {
+			select {
+			case p.events <- e:
+			default:
+			}
+		}
+
+		select {
+		case <-ticker.C:
+		case <-p.quit:
+			return
+		}
+	}
+}
+
+// Relieve returns a Fetcher that polls f less often under pressure:
+// at level n, the wait until the next fetch is 2^n times what f asked
+// for, up to level 10.
+func Relieve(f Fetcher, p *Pressure) Fetcher {
+	return &relievedFetcher{fetcher: f, pressure: p}
+}
+
+type relievedFetcher struct {
+	fetcher  Fetcher
+	pressure *Pressure
+}
+
+func (f *relievedFetcher) Fetch() (items []Item, next time.Time, err error) {
+	return f.FetchContext(context.Background())
+}
+
+func (f *relievedFetcher) FetchContext(ctx context.Context) (items []Item, next time.Time, err error) {
+	items, next, err = fetchContext(ctx, f.fetcher)
+	now := time.Now()
+	if d := next.Sub(now); d > 0 {
+		next = now.Add(d << min(f.pressure.Level(), 10)) // 1024 times is plenty
+	}
+	return items, next, err
+}
+
+// Shed returns a Classifier that stops calling c once p reaches level:
+// items then score 0, so routes by score see them as unimportant, and
+// a slow classifier costs nothing until the pressure drops again.
+func Shed(c Classifier, p *Pressure, level int) Classifier {
+	return ClassifierFunc(func(it Item) float64 {
+		if p.Level() >= level {
+			return 0
+		}
+		return c.Score(it)
+	})
+}
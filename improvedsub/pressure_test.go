@@ -0,0 +1,65 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+// underPressure returns a Pressure at level 1 from its first sample:
+// any heap at all is past the limit.
+func underPressure(t *testing.T) *Pressure {
+	t.Helper()
+	p := WatchPressure(PressureLimits{Heap: []uint64{1}}, time.Hour)
+	t.Cleanup(p.Stop)
+	select {
+	case e := <-p.Events():
+		if e.Level != 1 {
+			t.Fatalf("level %d; want 1", e.Level)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no event for the first sample")
+	}
+	return p
+}
+
+func TestPressureStopClosesEvents(t *testing.T) {
+	p := underPressure(t)
+	p.Stop()
+	p.Stop() // again, as the cleanup will
+	if _, ok := <-p.Events(); ok {
+		t.Fatal("event after Stop")
+	}
+	if p.Level() != 1 {
+		t.Fatalf("level %d after Stop; want it kept at 1", p.Level())
+	}
+}
+
+func TestShedSkipsClassifier(t *testing.T) {
+	p := underPressure(t)
+	called := false
+	c := ClassifierFunc(func(Item) float64 { called = true; return 5 })
+
+	if got := Shed(c, p, 2).Score(Item{}); got != 5 || !called {
+		t.Fatalf("below the level: score %v, called %v; want 5, true", got, called)
+	}
+	called = false
+	if got := Shed(c, p, 1).Score(Item{}); got != 0 || called {
+		t.Fatalf("at the level: score %v, called %v; want 0, false", got, called)
+	}
+}
+
+func TestRelievedBroadcastShrinksBuffers(t *testing.T) {
+	p := underPressure(t)
+	in := make(chanSub)
+	b := NewRelievedBroadcast(in, 4, DropOldest, p)
+	defer b.Close()
+	b.NewNamedReader("slow", LagLimits{})
+
+	for i := range 5 {
+		in <- Item{GUID: string(rune('a' + i))}
+	}
+	// Lag is answered by the broadcast loop, after the last item
+	if got := b.Lag()["slow"].Items; got != 2 {
+		t.Fatalf("reader buffers %d items; want 4 halved to 2", got)
+	}
+}
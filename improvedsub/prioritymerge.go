@@ -0,0 +1,37 @@
+package main
+
+import "sort"
+
+// MergeWithPriority merges the subscriptions in priorities, and whenever
+// items from several of them are ready, delivers the one from the
+// subscription with the highest priority first. Subscriptions with equal
+// priority are served round-robin.
+func MergeWithPriority(priorities map[Subscription]int) Subscription {
+	subs := make([]Subscription, 0, len(priorities))
+	for s := range priorities {
+		subs = append(subs, s)
+	}
+	sort.SliceStable(subs, func(i, j int) bool {
+		return priorities[subs[i]] > priorities[subs[j]]
+	})
+
+	return newBufferedMerge(subs, func(buffered [][]Item, last int) int {
+		// subs is sorted, so the first group with a ready item wins,
+		// and within it the search starts after the child served last.
+		for start := 0; start < len(subs); {
+			end := start
+			for end < len(subs) && priorities[subs[end]] == priorities[subs[start]] {
+				end++
+			}
+			n := end - start
+			for k := range n {
+				i := start + ((last+1-start+k)%n+n)%n
+				if len(buffered[i]) > 0 {
+					return i
+				}
+			}
+			start = end
+		}
+		return -1
+	})
+}
@@ -0,0 +1,216 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ErrQueueClosed is returned by Push once the queue has been closed,
+// and by Pop once it is closed and empty.
+var ErrQueueClosed = errors.New("queue: closed")
+
+// Queue is a bounded queue for any number of producers and consumers.
+// It is a buffered channel underneath; what it adds is a non-blocking
+// TryPush, a close that producers can race with safely, and counters.
+type Queue[T any] struct {
+	items  chan T
+	closed chan struct{}
+	once   sync.Once
+
+	pushed, popped, rejected, waited atomic.Int64
+}
+
+// QueueStats counts what a Queue has been through.
+type QueueStats struct {
+	Len, Cap int
+	Pushed   int64
+	Popped   int64
+	Rejected int64 // TryPush calls that found the queue full
+	Waited   int64 // Push calls that had to wait for room: backpressure
+}
+
+// NewQueue returns a queue holding up to size items.
+func NewQueue[T any](size int) *Queue[T] {
+	return &Queue[T]{
+		items:  make(chan T, max(size, 1)),
+		closed: make(chan struct{}),
+	}
+}
+
+// Push adds v, waiting for room until ctx is done or the queue is
+// closed.
+func (q *Queue[T]) Push(ctx context.Context, v T) error {
+	select {
+	case <-q.closed:
+		return ErrQueueClosed
+	default:
+	}
+	select {
+	case q.items <- v:
+		q.pushed.Add(1)
+		return nil
+	default:
+	}
+	q.waited.Add(1)
+	select {
+	case q.items <- v:
+		q.pushed.Add(1)
+		return nil
+	case <-q.closed:
+		return ErrQueueClosed
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// TryPush adds v if there is room right away.
+func (q *Queue[T]) TryPush(v T) bool {
+	select {
+	case <-q.closed:
+		return false
+	default:
+	}
+	select {
+	case q.items <- v:
+		q.pushed.Add(1)
+		return true
+	default:
+		q.rejected.Add(1)
+		return false
+	}
+}
+
+// Pop takes the oldest item, waiting until there is one, the queue is
+// closed or ctx is done. Items still queued when the queue is closed
+// can be popped; after them Pop returns ErrQueueClosed.
+func (q *Queue[T]) Pop(ctx context.Context) (T, error) {
+	var zero T
+	select {
+	case v := <-q.items:
+		q.popped.Add(1)
+		return v, nil
+	case <-q.closed:
+		// Closed, but items may still be queued: prefer them
+		select {
+		case v := <-q.items:
+			q.popped.Add(1)
+			return v, nil
+		default:
+			return zero, ErrQueueClosed
+		}
+	case <-ctx.Done():
+		return zero, ctx.Err()
+	}
+}
+
+// Close makes further pushes fail. It can be called more than once.
+func (q *Queue[T]) Close() {
+	q.once.Do(func() { close(q.closed) })
+}
+
+// Stats returns the current length and the counters so far.
+func (q *Queue[T]) Stats() QueueStats {
+	return QueueStats{
+		Len:      len(q.items),
+		Cap:      cap(q.items),
+		Pushed:   q.pushed.Load(),
+		Popped:   q.popped.Load(),
+		Rejected: q.rejected.Load(),
+		Waited:   q.waited.Load(),
+	}
+}
+
+// SubscribeQueued is like Subscribe, but keeps pending items in q
+// instead of the loop's own slice. Fetching and delivery run in
+// separate goroutines joined only by q: when the consumer falls
+// behind, pushing the fetched items blocks and the next fetch waits,
+// which shows up in q's Waited count.
+func SubscribeQueued(fetcher Fetcher, q *Queue[Item]) Subscription {
+	ctx, cancel := context.WithCancel(context.Background())
+	s := &queuedSub{
+		fetcher: fetcher,
+		q:       q,
+		updates: make(chan Item),
+		cancel:  cancel,
+	}
+	s.wg.Add(2)
+	go s.produce(ctx)
+	go s.consume(ctx)
+	return s
+}
+
+type queuedSub struct {
+	fetcher Fetcher
+	q       *Queue[Item]
+	updates chan Item
+	cancel  context.CancelFunc
+	wg      sync.WaitGroup
+	once    sync.Once
+	err     error // last fetch error, owned by produce until wg is done
+}
+
+func (s *queuedSub) Updates() <-chan Item {
+	return s.updates
+}
+
+func (s *queuedSub) Close() error {
+	s.once.Do(func() {
+		s.cancel()
+		s.wg.Wait()
+		s.q.Close()
+		close(s.updates)
+	})
+	return s.err
+}
+
+func (s *queuedSub) produce(ctx context.Context) {
+	defer s.wg.Done()
+	seen := make(seenMap)
+	var next time.Time
+	for {
+		select {
+		case <-time.After(time.Until(next)):
+		case <-ctx.Done():
+			return
+		}
+		items, n, err := fetchContext(ctx, s.fetcher)
+		if ctx.Err() != nil {
+			return // closed mid-fetch
+		}
+		next, s.err = n, err
+		if errors.Is(s.err, ErrRateLimited) {
+			s.err = nil
+			continue
+		}
+		if s.err != nil {
+			next = time.Now().Add(10 * time.Second)
+			continue
+		}
+		for _, it := range items {
+			if !seen.Add(it.GUID) {
+				continue
+			}
+			if s.q.Push(ctx, it) != nil {
+				return
+			}
+		}
+	}
+}
+
+func (s *queuedSub) consume(ctx context.Context) {
+	defer s.wg.Done()
+	for {
+		it, err := s.q.Pop(ctx)
+		if err != nil {
+			return
+		}
+		select {
+		case s.updates <- it:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
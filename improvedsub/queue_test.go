@@ -0,0 +1,49 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestQueuePopDrainsThenFailsWhenClosed(t *testing.T) {
+	q := NewQueue[int](4)
+	ctx := context.Background()
+	for i := range 3 {
+		if err := q.Push(ctx, i); err != nil {
+			t.Fatal(err)
+		}
+	}
+	q.Close()
+
+	for want := range 3 {
+		got, err := q.Pop(ctx)
+		if err != nil || got != want {
+			t.Fatalf("Pop = %d, %v; want %d, nil", got, err, want)
+		}
+	}
+	if _, err := q.Pop(ctx); !errors.Is(err, ErrQueueClosed) {
+		t.Fatalf("Pop on a closed, empty queue = %v; want ErrQueueClosed", err)
+	}
+}
+
+func TestQueueCloseWakesWaitingPop(t *testing.T) {
+	q := NewQueue[int](1)
+	errc := make(chan error, 1)
+	go func() {
+		_, err := q.Pop(context.Background())
+		errc <- err
+	}()
+	time.Sleep(10 * time.Millisecond) // let Pop wait
+	q.Close()
+
+	select {
+	case err := <-errc:
+		if !errors.Is(err, ErrQueueClosed) {
+			t.Fatalf("Pop = %v; want ErrQueueClosed", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Pop still waiting after Close")
+	}
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// ErrRateLimited is returned, possibly wrapped, by a Fetcher that did
+// not fetch because of a rate limit. The subscription loop does not
+// treat it as a failure: it just tries again at the time Fetch
+// returned as next.
+var ErrRateLimited = errors.New("rate limited")
+
+// HTTPLimits is shared by all HTTP fetchers. It has no limits until
+// they are configured, for instance:
+//
+//	HTTPLimits.Global = NewTokenBucket(20, 20)
+//	HTTPLimits.SetHost("api.github.com", 1, 5)
+var HTTPLimits = &RateLimits{}
+
+// RateLimits caps requests globally and per host. A request needs a
+// token from Global and one from its host's bucket; hosts without one
+// of their own get a bucket of PerHost requests per second with burst
+// HostBurst, or no limit if PerHost is zero.
+type RateLimits struct {
+	Global    *TokenBucket
+	PerHost   float64
+	HostBurst int
+
+	mu    sync.Mutex
+	hosts map[string]*TokenBucket
+}
+
+// SetHost gives host a limit of its own.
+func (l *RateLimits) SetHost(host string, rate float64, burst int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.hosts == nil {
+		l.hosts = make(map[string]*TokenBucket)
+	}
+	l.hosts[host] = NewTokenBucket(rate, burst)
+}
+
+// Take takes the tokens for a request to host and returns zero, or
+// takes nothing and returns how long to wait before trying again.
+func (l *RateLimits) Take(host string) time.Duration {
+	if l == nil {
+		return 0
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	var buckets []*TokenBucket
+	if l.Global != nil {
+		buckets = append(buckets, l.Global)
+	}
+	if b := l.host(host); b != nil {
+		buckets = append(buckets, b)
+	}
+	var wait time.Duration
+	for _, b := range buckets {
+		wait = max(wait, b.Delay())
+	}
+	if wait > 0 {
+		return wait
+	}
+	for _, b := range buckets {
+		b.Allow()
+	}
+	return 0
+}
+
+func (l *RateLimits) host(host string) *TokenBucket {
+	if b, ok := l.hosts[host]; ok {
+		return b
+	}
+	if l.PerHost <= 0 {
+		return nil
+	}
+	if l.hosts == nil {
+		l.hosts = make(map[string]*TokenBucket)
+	}
+	b := NewTokenBucket(l.PerHost, max(l.HostBurst, 1))
+	l.hosts[host] = b
+	return b
+}
+
+// retryAfter returns when a 429 response says to come back, or def if
+// it does not say.
+func retryAfter(resp *http.Response, def time.Time) time.Time {
+	v := resp.Header.Get("Retry-After")
+	if secs, err := strconv.Atoi(v); err == nil {
+		return time.Now().Add(time.Duration(secs) * time.Second)
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		return t
+	}
+	return def
+}
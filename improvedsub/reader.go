@@ -1,12 +1,18 @@
 package main
 
 import (
+	"context"
+	"flag"
 	"fmt"
+	"os"
 	"time"
 )
 
 type Item struct {
-	Title, Channel, GUID string // subset of RSS fields
+	Title, Channel, GUID string    // subset of RSS fields
+	Published            time.Time // publish time, zero if unknown
+	Tags                 []string  // set by the feed, see Tag
+	Score                float64   // set by Classify
 }
 
 type Fetcher interface {
@@ -15,6 +21,14 @@ type Fetcher interface {
 	Fetch() (items []Item, next time.Time, err error)
 }
 
+// ContextFetcher is a Fetcher whose fetches can be cancelled. A
+// subscription closed mid-fetch cancels ctx instead of leaving the
+// fetch to run to completion.
+type ContextFetcher interface {
+	Fetcher
+	FetchContext(ctx context.Context) (items []Item, next time.Time, err error)
+}
+
 // Subscription delivers Items over a channel.
 // Close cancels the subscription, closes the Updates channel and
 // returns the last fetch error, if any.
@@ -24,6 +38,16 @@ type Subscription interface {
 }
 
 func main() {
+	seed := flag.Int64("seed", 0, "seed for the fake feeds' delays, random if 0")
+	flag.Parse()
+	if flag.Arg(0) == "follow" {
+		os.Exit(follow(flag.Args()[1:]))
+	}
+	if *seed == 0 {
+		*seed = time.Now().UnixNano()
+	}
+	FakeSeed = *seed
+	fmt.Println("Seed:", FakeSeed) // rerun with -seed to reproduce
 
 	// Subscribe to some feeds and create a merged update stream
 	merged := Merge(
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"time"
+)
+
+// Replicate returns a Fetcher that fetches from every mirror at once
+// and returns the first successful result, the replication trick from
+// the Google Search 3.0 example. Once one mirror has answered, the
+// slower ones are cancelled if they are ContextFetchers; the others
+// are abandoned, their results going to a buffered channel nobody
+// reads so their goroutines still exit. A mirror is only ever called
+// once at a time, since a Fetcher keeps state: one still busy with an
+// earlier fetch sits the next one out. If every mirror fails the last
+// error is returned.
+func Replicate(mirrors ...Fetcher) Fetcher {
+	return &replicatedFetcher{mirrors: mirrors, busy: make([]atomic.Bool, len(mirrors))}
+}
+
+// ErrMirrorsBusy is returned by a replicated fetch when every mirror is
+// still busy with an earlier one.
+var ErrMirrorsBusy = errors.New("replicated fetcher: every mirror is still busy")
+
+type replicatedFetcher struct {
+	mirrors []Fetcher
+	busy    []atomic.Bool // set while a mirror's fetch runs
+}
+
+func (f *replicatedFetcher) Fetch() (items []Item, next time.Time, err error) {
+	return f.FetchContext(context.Background())
+}
+
+func (f *replicatedFetcher) FetchContext(ctx context.Context) (items []Item, next time.Time, err error) {
+	if len(f.mirrors) == 0 {
+		return nil, time.Time{}, errors.New("replicated fetcher: no mirrors")
+	}
+	type fetchResult struct {
+		fetched []Item
+		next    time.Time
+		err     error
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	results := make(chan fetchResult, len(f.mirrors))
+	started := 0
+	for i, m := range f.mirrors {
+		if !f.busy[i].CompareAndSwap(false, true) {
+			continue // an abandoned fetch from an earlier call
+		}
+		started++
+		go func() {
+			defer f.busy[i].Store(false)
+			fetched, next, err := fetchContext(ctx, m)
+			results <- fetchResult{fetched, next, err}
+		}()
+	}
+	if started == 0 {
+		return nil, time.Time{}, ErrMirrorsBusy
+	}
+	for range started {
+		r := <-results
+		if r.err == nil {
+			return r.fetched, r.next, nil
+		}
+		next, err = r.next, r.err
+	}
+	return nil, next, err
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// stuckFetcher blocks every Fetch until release is closed, ignoring
+// any context, and counts the calls running at once.
+type stuckFetcher struct {
+	release        chan struct{}
+	calls, running atomic.Int32
+	overlapped     atomic.Bool
+}
+
+func (f *stuckFetcher) Fetch() ([]Item, time.Time, error) {
+	f.calls.Add(1)
+	if f.running.Add(1) > 1 {
+		f.overlapped.Store(true)
+	}
+	defer f.running.Add(-1)
+	<-f.release
+	return nil, time.Time{}, errors.New("too late")
+}
+
+func TestReplicateSkipsBusyMirrors(t *testing.T) {
+	stuck := &stuckFetcher{release: make(chan struct{})}
+	fast := NewScriptedFetcher(RealClock,
+		Response{Items: []Item{{GUID: "a"}}},
+		Response{Items: []Item{{GUID: "b"}}})
+	f := Replicate(stuck, fast)
+
+	for _, want := range []string{"a", "b"} {
+		items, _, err := f.Fetch()
+		if err != nil || len(items) != 1 || items[0].GUID != want {
+			t.Fatalf("Fetch = %v, %v; want item %s", items, err, want)
+		}
+		for stuck.calls.Load() == 0 {
+			time.Sleep(time.Millisecond)
+		}
+	}
+	close(stuck.release)
+	time.Sleep(20 * time.Millisecond) // time for a second call to show
+	if n := stuck.calls.Load(); n != 1 {
+		t.Errorf("the stuck mirror was called %d times; want once", n)
+	}
+	if stuck.overlapped.Load() {
+		t.Error("the stuck mirror was called again while still busy")
+	}
+}
+
+func TestReplicateAllMirrorsBusy(t *testing.T) {
+	stuck := &stuckFetcher{release: make(chan struct{})}
+	defer close(stuck.release)
+	f := Replicate(stuck)
+	go f.Fetch() // takes the only mirror
+	for stuck.calls.Load() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	if _, _, err := f.Fetch(); !errors.Is(err, ErrMirrorsBusy) {
+		t.Fatalf("Fetch = %v; want ErrMirrorsBusy", err)
+	}
+}
@@ -0,0 +1,43 @@
+package main
+
+import "context"
+
+// RetrySink delivers to a Sink through a JobQueue: Send only queues the
+// item, blocking while the queue is full, and a failed delivery is
+// retried in the background by the policy instead of being reported to
+// the caller. Items that fail every try end up in the dead letters.
+type RetrySink struct {
+	q *JobQueue[Item]
+}
+
+// NewRetrySink starts workers goroutines delivering to sink, with at
+// most size items waiting.
+func NewRetrySink(sink Sink, size, workers int, policy RetryPolicy) *RetrySink {
+	return &RetrySink{q: NewJobQueue(size, workers, policy, func(_ context.Context, it Item) error {
+		return sink.Send(it)
+	})}
+}
+
+// Send queues it for delivery. It fails only once Shutdown has been
+// called.
+func (s *RetrySink) Send(it Item) error {
+	return s.q.Submit(context.Background(), it)
+}
+
+// Shutdown waits for the queued items to be delivered or fail for good,
+// like JobQueue.Shutdown. A Send already in progress when ctx is done
+// is not interrupted; the Sink interface gives it no context.
+func (s *RetrySink) Shutdown(ctx context.Context) error {
+	return s.q.Shutdown(ctx)
+}
+
+// DeadLetters returns the items that failed for good so far, and
+// forgets them.
+func (s *RetrySink) DeadLetters() []Job[Item] {
+	return s.q.DeadLetters()
+}
+
+// Stats returns the counters of the underlying queue.
+func (s *RetrySink) Stats() JobStats {
+	return s.q.Stats()
+}
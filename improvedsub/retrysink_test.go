@@ -0,0 +1,74 @@
+package main
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRouterRetriesFailedSends(t *testing.T) {
+	AssertNoLeaks(t, func() {
+		var mu sync.Mutex
+		tries := make(map[string]int)
+		var delivered []string
+		flaky := SinkFunc(func(it Item) error { // fails each item twice
+			mu.Lock()
+			defer mu.Unlock()
+			if tries[it.GUID]++; tries[it.GUID] < 3 {
+				return errors.New("unavailable")
+			}
+			delivered = append(delivered, it.GUID)
+			return nil
+		})
+		down := SinkFunc(func(Item) error { return errors.New("down") })
+
+		r, err := NewRouter(map[string]Sink{"flaky": flaky, "down": down}, []RouteRule{
+			{Sinks: []string{"flaky"}},
+			{Match: `guid == "b"`, Sinks: []string{"down"}},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		r.Retry(1, 2, RetryPolicy{Attempts: 3, Backoff: time.Millisecond})
+
+		in := make(chanSub)
+		done := make(chan error, 1)
+		go func() { done <- r.Run(in) }()
+		for _, guid := range []string{"a", "b", "c"} {
+			in <- Item{GUID: guid}
+		}
+		close(in)
+
+		select {
+		case err = <-done:
+		case <-time.After(5 * time.Second):
+			t.Fatal("Run did not return")
+		}
+		if err == nil || !strings.Contains(err.Error(), "sink down: 1 items failed for good, last after 3 attempts: down") {
+			t.Errorf("Run = %v; want the dead letter of sink down", err)
+		}
+		slices.Sort(delivered)
+		if !slices.Equal(delivered, []string{"a", "b", "c"}) {
+			t.Errorf("delivered %v, want [a b c]", delivered)
+		}
+	})
+}
+
+func TestRetrySinkSendAfterShutdown(t *testing.T) {
+	s := NewRetrySink(SinkFunc(func(Item) error { return nil }), 1, 1, RetryPolicy{})
+	if err := s.Send(Item{GUID: "a"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Shutdown(t.Context()); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Send(Item{GUID: "b"}); !errors.Is(err, ErrJobQueueClosed) {
+		t.Errorf("Send after Shutdown = %v; want ErrJobQueueClosed", err)
+	}
+	if st := s.Stats(); st.Submitted != 1 || st.Succeeded != 1 {
+		t.Errorf("Stats = %+v; want one submitted and succeeded", st)
+	}
+}
@@ -0,0 +1,46 @@
+package main
+
+// ring is a FIFO queue over a circular buffer. Appending to a slice
+// and reslicing it with [1:] keeps moving on to new backing arrays,
+// leaving the old ones to the garbage collector; ring reuses its slots
+// and only allocates when it has to grow past its largest size so far.
+type ring[T any] struct {
+	buf  []T
+	head int // index of the oldest element
+	n    int
+}
+
+func newRing[T any](size int) ring[T] {
+	return ring[T]{buf: make([]T, max(size, 1))}
+}
+
+func (r *ring[T]) len() int {
+	return r.n
+}
+
+func (r *ring[T]) push(v T) {
+	if r.n == len(r.buf) {
+		buf := make([]T, 2*len(r.buf))
+		for i := range r.n {
+			buf[i] = r.at(i)
+		}
+		r.buf, r.head = buf, 0
+	}
+	r.buf[(r.head+r.n)%len(r.buf)] = v
+	r.n++
+}
+
+// pop removes the oldest element. The ring must not be empty.
+func (r *ring[T]) pop() T {
+	v := r.buf[r.head]
+	var zero T
+	r.buf[r.head] = zero // don't keep it reachable
+	r.head = (r.head + 1) % len(r.buf)
+	r.n--
+	return v
+}
+
+// at returns the i-th oldest element.
+func (r *ring[T]) at(i int) T {
+	return r.buf[(r.head+i)%len(r.buf)]
+}
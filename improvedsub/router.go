@@ -0,0 +1,141 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+)
+
+// RouteRule sends the items it matches to the named sinks. An item
+// matches if it satisfies the Match expression (see CompileFilter),
+// carries one of Tags and scores at least MinScore (see Classify); any
+// of them may be left empty, or zero, to match everything.
+type RouteRule struct {
+	Match    string   `json:"match,omitempty"`
+	Tags     []string `json:"tags,omitempty"`
+	MinScore float64  `json:"min_score,omitempty"`
+	Sinks    []string `json:"sinks"`
+}
+
+// LoadRouteRules reads a JSON array of RouteRules, e.g.
+//
+//	[{"match": "channel == \"security\"", "sinks": ["webhook", "email"]},
+//	 {"sinks": ["archive"]}]
+func LoadRouteRules(r io.Reader) ([]RouteRule, error) {
+	var rules []RouteRule
+	if err := json.NewDecoder(r).Decode(&rules); err != nil {
+		return nil, err
+	}
+	return rules, nil
+}
+
+// Router delivers each item only to the sinks whose rules match it,
+// instead of every sink seeing the whole merged stream.
+type Router struct {
+	routes []route
+	sinks  map[string]Sink
+	retry  *routerRetry // set by Retry
+}
+
+type routerRetry struct {
+	size, workers int
+	policy        RetryPolicy
+}
+
+type route struct {
+	match func(Item) bool
+	sinks []string
+}
+
+// NewRouter compiles rules against the given sinks, by name.
+func NewRouter(sinks map[string]Sink, rules []RouteRule) (*Router, error) {
+	r := &Router{}
+	for i, rule := range rules {
+		rt := route{match: func(Item) bool { return true }}
+		if rule.Match != "" {
+			f, err := CompileFilter(rule.Match)
+			if err != nil {
+				return nil, fmt.Errorf("route %d: %v", i, err)
+			}
+			rt.match = f
+		}
+		if len(rule.Tags) > 0 {
+			rt.match = andFilter(rt.match, AnyTag(rule.Tags...))
+		}
+		if minScore := rule.MinScore; minScore != 0 {
+			rt.match = andFilter(rt.match, func(it Item) bool { return it.Score >= minScore })
+		}
+		for _, name := range rule.Sinks {
+			if _, ok := sinks[name]; !ok {
+				return nil, fmt.Errorf("route %d: unknown sink %q", i, name)
+			}
+		}
+		rt.sinks = rule.Sinks
+		r.routes = append(r.routes, rt)
+	}
+	r.sinks = sinks
+	return r, nil
+}
+
+// Sinks returns the names of the sinks it should be sent to, each once,
+// in the order the rules name them.
+func (r *Router) Sinks(it Item) []string {
+	var names []string
+	for _, rt := range r.routes {
+		if !rt.match(it) {
+			continue
+		}
+		for _, name := range rt.sinks {
+			if !slices.Contains(names, name) {
+				names = append(names, name)
+			}
+		}
+	}
+	return names
+}
+
+// Retry makes Run deliver to each sink through a RetrySink of its own,
+// with at most size items waiting and workers goroutines sending, so a
+// failed Send is retried by policy and a slow sink holds up the others
+// only once its queue is full. Call it before Run.
+func (r *Router) Retry(size, workers int, policy RetryPolicy) {
+	r.retry = &routerRetry{size: size, workers: workers, policy: policy}
+}
+
+// Run routes the items of s until its Updates channel is closed, that
+// is until s is closed. A failing sink does not stop the others; Run
+// returns the first error any of them reported. With Retry, Run waits
+// for the queued items before returning, and an error means some of
+// them failed every try.
+func (r *Router) Run(s Subscription) (err error) {
+	sinks := r.sinks
+	if r.retry != nil {
+		retrying := make(map[string]*RetrySink, len(r.sinks))
+		sinks = make(map[string]Sink, len(r.sinks))
+		for name, sink := range r.sinks {
+			retrying[name] = NewRetrySink(sink, r.retry.size, r.retry.workers, r.retry.policy)
+			sinks[name] = retrying[name]
+		}
+		defer func() {
+			for name, rs := range retrying {
+				rs.Shutdown(context.Background())
+				if dead := rs.DeadLetters(); len(dead) > 0 && err == nil {
+					last := dead[len(dead)-1]
+					err = fmt.Errorf("sink %s: %d items failed for good, last after %d attempts: %v",
+						name, len(dead), last.Attempts, last.Err)
+				}
+			}
+		}()
+	}
+
+	for it := range s.Updates() {
+		for _, name := range r.Sinks(it) {
+			if e := sinks[name].Send(it); e != nil && err == nil {
+				err = fmt.Errorf("sink %s: %v", name, e)
+			}
+		}
+	}
+	return
+}
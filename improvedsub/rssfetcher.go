@@ -0,0 +1,144 @@
+package main
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// FetchRSS returns a Fetcher for the RSS 2.0 or Atom feed at url,
+// polled every interval, or as often as the feed's <ttl> asks if it is
+// longer. Items are published as of their pubDate, published or
+// updated element, and keyed by their guid or id, falling back to the
+// link and then the title.
+func FetchRSS(url string, interval time.Duration) (Fetcher, error) {
+	if err := checkUserAgent(HTTPUserAgent, url); err != nil {
+		return nil, fmt.Errorf("rss feed %s: %w", url, err)
+	}
+	return &rssFetcher{
+		url:      url,
+		interval: interval,
+		client:   &http.Client{Timeout: 30 * time.Second},
+		limits:   HTTPLimits,
+	}, nil
+}
+
+type rssFetcher struct {
+	url      string
+	interval time.Duration
+	client   *http.Client
+	limits   *RateLimits
+}
+
+// rssDoc holds both formats: an RSS document fills Channel, an Atom
+// one fills Title and Entries.
+type rssDoc struct {
+	Channel struct {
+		Title string    `xml:"title"`
+		TTL   int       `xml:"ttl"` // minutes
+		Items []rssItem `xml:"item"`
+	} `xml:"channel"`
+	Title   string      `xml:"title"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type rssItem struct {
+	Title   string `xml:"title"`
+	Link    string `xml:"link"`
+	GUID    string `xml:"guid"`
+	PubDate string `xml:"pubDate"`
+}
+
+type atomEntry struct {
+	Title string `xml:"title"`
+	Link  struct {
+		Href string `xml:"href,attr"`
+	} `xml:"link"`
+	ID        string `xml:"id"`
+	Published string `xml:"published"`
+	Updated   string `xml:"updated"`
+}
+
+func (f *rssFetcher) String() string {
+	return f.url
+}
+
+func (f *rssFetcher) Fetch() (items []Item, next time.Time, err error) {
+	return f.FetchContext(context.Background())
+}
+
+func (f *rssFetcher) FetchContext(ctx context.Context) (items []Item, next time.Time, err error) {
+	now := time.Now()
+	next = now.Add(f.interval)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, f.url, nil)
+	if err != nil {
+		return nil, next, err
+	}
+	if HTTPUserAgent != "" {
+		req.Header.Set("User-Agent", HTTPUserAgent)
+	}
+	if wait := f.limits.Take(req.URL.Host); wait > 0 {
+		return nil, now.Add(wait), fmt.Errorf("rss feed %s: %w", f.url, ErrRateLimited)
+	}
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return nil, next, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusTooManyRequests {
+		return nil, retryAfter(resp, next), fmt.Errorf("rss feed %s: %w", f.url, ErrRateLimited)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, next, fmt.Errorf("rss feed %s: %s", f.url, resp.Status)
+	}
+
+	var doc rssDoc
+	if err := xml.NewDecoder(resp.Body).Decode(&doc); err != nil {
+		return nil, next, fmt.Errorf("rss feed %s: %v", f.url, err)
+	}
+	if ttl := time.Duration(doc.Channel.TTL) * time.Minute; ttl > f.interval {
+		next = now.Add(ttl)
+	}
+	channel := strings.TrimSpace(doc.Channel.Title + doc.Title)
+	if channel == "" {
+		channel = f.url
+	}
+	for _, it := range doc.Channel.Items {
+		items = append(items, rssEntry(channel, it.Title, it.GUID, it.Link, it.PubDate))
+	}
+	for _, e := range doc.Entries {
+		published := e.Published
+		if published == "" {
+			published = e.Updated
+		}
+		items = append(items, rssEntry(channel, e.Title, e.ID, e.Link.Href, published))
+	}
+	return items, next, nil
+}
+
+func rssEntry(channel, title, id, link, published string) Item {
+	title = strings.TrimSpace(title)
+	item := Item{Channel: channel, Title: title}
+	switch {
+	case id != "":
+		item.GUID = channel + "/" + strings.TrimSpace(id)
+	case link != "":
+		item.GUID = channel + "/" + strings.TrimSpace(link)
+	default:
+		item.GUID = channel + "/" + title
+	}
+	// RSS dates are RFC 822 with four-digit years in practice, Atom
+	// dates RFC 3339. An unparseable date just leaves the item undated.
+	published = strings.TrimSpace(published)
+	for _, layout := range []string{time.RFC1123Z, time.RFC1123, time.RFC3339} {
+		if t, err := time.Parse(layout, published); err == nil {
+			item.Published = t
+			break
+		}
+	}
+	return item
+}
@@ -0,0 +1,284 @@
+package main
+
+import (
+	"container/heap"
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// Runtime runs many subscriptions on a fixed number of goroutines. A
+// subscription from Subscribe costs a goroutine for its loop plus one
+// per fetch in flight; a Runtime instead keeps the state of all its
+// subscriptions in one loop, which hands due fetches to workers
+// goroutines. The Updates channel of each subscription is buffered and
+// doubles as its pending queue, so the loop only ever sends without
+// blocking; items that don't fit yet wait in a backlog that is retried
+// every flush interval, and a subscription with a backlog is not
+// fetched again until it has drained.
+type Runtime struct {
+	flush   time.Duration
+	jobs    chan *rtSub
+	results chan rtResult
+	add     chan *rtSub
+	closing chan rtClose
+	quit    chan struct{}
+	done    chan struct{} // closed when loop returns
+	once    sync.Once     // for Stop
+}
+
+type rtSub struct {
+	rt      *Runtime
+	fetcher Fetcher
+	ctx     context.Context // for its fetches, cancelled when it is removed
+	cancel  context.CancelFunc
+	updates chan Item
+	seen    seenMap
+	backlog []Item // fetched but not yet in updates
+	next    time.Time
+	err     error
+	closed  bool
+	index   int // in the due heap, -1 if not in it
+}
+
+type rtResult struct {
+	rs    *rtSub
+	items []Item
+	next  time.Time
+	err   error
+}
+
+type rtClose struct {
+	rs   *rtSub
+	errc chan error
+}
+
+// NewRuntime starts a runtime fetching with workers goroutines. flush
+// is how often items that did not fit in a full Updates buffer are
+// tried again, every 100ms if it is not positive.
+func NewRuntime(workers int, flush time.Duration) *Runtime {
+	if flush <= 0 {
+		flush = 100 * time.Millisecond
+	}
+	r := &Runtime{
+		flush:   flush,
+		jobs:    make(chan *rtSub),
+		results: make(chan rtResult),
+		add:     make(chan *rtSub),
+		closing: make(chan rtClose),
+		quit:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+	for range max(workers, 1) {
+		go r.worker()
+	}
+	go r.loop()
+	return r
+}
+
+// Subscribe is like the package-level Subscribe, with the subscription
+// run by r.
+func (r *Runtime) Subscribe(fetcher Fetcher) Subscription {
+	const maxPending = 10
+	ctx, cancel := context.WithCancel(context.Background())
+	rs := &rtSub{
+		rt:      r,
+		fetcher: fetcher,
+		ctx:     ctx,
+		cancel:  cancel,
+		updates: make(chan Item, maxPending),
+		seen:    make(seenMap),
+		index:   -1,
+	}
+	select {
+	case r.add <- rs:
+	case <-r.done:
+		cancel()
+		close(rs.updates)
+	}
+	return rs
+}
+
+// Stop closes every subscription still running, cancelling their
+// fetches in flight, and stops the runtime. It can be called more
+// than once.
+func (r *Runtime) Stop() {
+	r.once.Do(func() {
+		close(r.quit)
+		<-r.done
+	})
+}
+
+func (rs *rtSub) Updates() <-chan Item {
+	return rs.updates
+}
+
+func (rs *rtSub) Close() error {
+	errc := make(chan error)
+	select {
+	case rs.rt.closing <- rtClose{rs, errc}:
+		return <-errc
+	case <-rs.rt.done:
+		return rs.err
+	}
+}
+
+func (r *Runtime) worker() {
+	for {
+		select {
+		case rs := <-r.jobs:
+			items, next, err := fetchContext(rs.ctx, rs.fetcher)
+			select {
+			case r.results <- rtResult{rs, items, next, err}:
+			case <-r.quit:
+				return
+			}
+		case <-r.quit:
+			return
+		}
+	}
+}
+
+func (r *Runtime) loop() {
+	defer close(r.done)
+	var due dueHeap
+	var ready []*rtSub // due, waiting for a free worker
+	live := make(map[*rtSub]bool)
+	backlogged := make(map[*rtSub]bool) // with items waiting for room
+
+	timer := time.NewTimer(0)
+	timer.Stop()
+	defer timer.Stop()
+	flush := time.NewTicker(r.flush)
+	defer flush.Stop()
+
+	for {
+		timer.Stop()
+		if len(due) > 0 {
+			timer.Reset(time.Until(due[0].next))
+		}
+
+		var jobs chan *rtSub
+		var job *rtSub
+		if len(ready) > 0 {
+			jobs, job = r.jobs, ready[0]
+		}
+
+		select {
+		case rs := <-r.add:
+			rs.next = time.Now()
+			live[rs] = true
+			heap.Push(&due, rs)
+		case c := <-r.closing:
+			if !c.rs.closed {
+				r.remove(c.rs, &due, &ready)
+				delete(live, c.rs)
+				delete(backlogged, c.rs)
+			}
+			c.errc <- c.rs.err
+		case now := <-timer.C:
+			for len(due) > 0 && !due[0].next.After(now) {
+				rs := heap.Pop(&due).(*rtSub)
+				ready = append(ready, rs)
+			}
+		case jobs <- job:
+			ready[0] = nil
+			ready = ready[1:]
+		case res := <-r.results:
+			rs := res.rs
+			if rs.closed {
+				break
+			}
+			rs.next, rs.err = res.next, res.err
+			switch {
+			case errors.Is(res.err, ErrRateLimited):
+				rs.err = nil
+			case res.err != nil:
+				rs.next = time.Now().Add(10 * time.Second)
+			default:
+				for _, it := range res.items {
+					if rs.seen.Add(it.GUID) {
+						rs.backlog = append(rs.backlog, it)
+					}
+				}
+			}
+			if !rs.deliver() {
+				backlogged[rs] = true
+				break
+			}
+			heap.Push(&due, rs)
+		case <-flush.C:
+			for rs := range backlogged {
+				if rs.deliver() {
+					delete(backlogged, rs)
+					heap.Push(&due, rs)
+				}
+			}
+		case <-r.quit:
+			for rs := range live {
+				r.remove(rs, &due, &ready)
+			}
+			return
+		}
+	}
+}
+
+// deliver moves as much of the backlog into Updates as fits, and
+// reports whether it all did.
+func (rs *rtSub) deliver() bool {
+	for len(rs.backlog) > 0 {
+		select {
+		case rs.updates <- rs.backlog[0]:
+			rs.backlog = rs.backlog[1:]
+		default:
+			return false
+		}
+	}
+	rs.backlog = nil
+	return true
+}
+
+// remove takes rs out of the loop's bookkeeping and closes it. A fetch
+// still in flight for it is cancelled, and dropped when it comes back.
+func (r *Runtime) remove(rs *rtSub, due *dueHeap, ready *[]*rtSub) {
+	if rs.index >= 0 {
+		heap.Remove(due, rs.index)
+	}
+	for i, s := range *ready {
+		if s == rs {
+			*ready = append((*ready)[:i], (*ready)[i+1:]...)
+			break
+		}
+	}
+	rs.closed = true
+	rs.cancel()
+	close(rs.updates)
+}
+
+// dueHeap orders subscriptions by their next fetch.
+type dueHeap []*rtSub
+
+func (h dueHeap) Len() int           { return len(h) }
+func (h dueHeap) Less(i, j int) bool { return h[i].next.Before(h[j].next) }
+
+func (h dueHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *dueHeap) Push(x any) {
+	rs := x.(*rtSub)
+	rs.index = len(*h)
+	*h = append(*h, rs)
+}
+
+func (h *dueHeap) Pop() any {
+	old := *h
+	rs := old[len(old)-1]
+	old[len(old)-1] = nil
+	rs.index = -1
+	*h = old[:len(old)-1]
+	return rs
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// hungFetcher blocks every fetch until its context is done.
+type hungFetcher struct {
+	started   chan struct{}
+	cancelled chan struct{}
+}
+
+func newHungFetcher() *hungFetcher {
+	return &hungFetcher{started: make(chan struct{}, 1), cancelled: make(chan struct{}, 1)}
+}
+
+func (f *hungFetcher) Fetch() ([]Item, time.Time, error) {
+	return f.FetchContext(context.Background())
+}
+
+func (f *hungFetcher) FetchContext(ctx context.Context) ([]Item, time.Time, error) {
+	f.started <- struct{}{}
+	<-ctx.Done()
+	f.cancelled <- struct{}{}
+	return nil, time.Time{}, ctx.Err()
+}
+
+func waitSignal(t *testing.T, c <-chan struct{}, what string) {
+	t.Helper()
+	select {
+	case <-c:
+	case <-time.After(5 * time.Second):
+		t.Fatal(what)
+	}
+}
+
+func TestRuntimeCloseCancelsFetch(t *testing.T) {
+	r := NewRuntime(1, 0)
+	defer r.Stop()
+	f := newHungFetcher()
+	s := r.Subscribe(f)
+	waitSignal(t, f.started, "fetch did not start")
+	s.Close()
+	waitSignal(t, f.cancelled, "closing the subscription did not cancel its fetch")
+
+	// The worker is free again for the next subscription
+	g := newHungFetcher()
+	r.Subscribe(g)
+	waitSignal(t, g.started, "the worker is still stuck in the cancelled fetch")
+}
+
+func TestRuntimeStopCancelsFetchesAndCanRepeat(t *testing.T) {
+	r := NewRuntime(1, 0)
+	f := newHungFetcher()
+	r.Subscribe(f)
+	waitSignal(t, f.started, "fetch did not start")
+	r.Stop()
+	waitSignal(t, f.cancelled, "Stop did not cancel the fetch")
+	r.Stop()
+}
@@ -0,0 +1,266 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// Scenarios run subscriptions end to end against feed servers in this
+// process: real HTTP through FetchJSON, the real loop, and Merge and
+// Dedup wired up the way a reader would. Run them from this directory
+// with go test -race $(ls *.go).
+
+// feedServer serves a JSON feed whose n-th request, counting from 1,
+// is answered by respond.
+type feedServer struct {
+	*httptest.Server
+	requests atomic.Int64
+}
+
+func newFeedServer(t *testing.T, respond func(n int64, w http.ResponseWriter, r *http.Request)) *feedServer {
+	s := &feedServer{}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		respond(s.requests.Add(1), w, r)
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+// entries answers with entries with the given ids.
+func entries(w http.ResponseWriter, ids ...int) {
+	type entry struct {
+		ID    int    `json:"id"`
+		Title string `json:"title"`
+	}
+	doc := struct {
+		Items []entry `json:"items"`
+	}{Items: []entry{}}
+	for _, id := range ids {
+		doc.Items = append(doc.Items, entry{id, fmt.Sprintf("Entry %d", id)})
+	}
+	json.NewEncoder(w).Encode(doc)
+}
+
+func (s *feedServer) fetcher(t *testing.T, interval string) Fetcher {
+	f, err := FetchJSON(JSONFeed{
+		URL:      s.URL,
+		Channel:  "news",
+		Items:    "items",
+		Title:    "title",
+		ID:       "id",
+		Interval: interval,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return f
+}
+
+// noLeaks is AssertNoLeaks for scenarios. Servers are started before
+// it, so their listeners don't count; the client's idle connections
+// are closed at the end, which ends the server side of them too.
+func noLeaks(t *testing.T, body func()) {
+	t.Helper()
+	AssertNoLeaks(t, func() {
+		body()
+		http.DefaultTransport.(*http.Transport).CloseIdleConnections()
+	})
+}
+
+// eventually fails t unless cond holds within a few seconds.
+func eventually(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	for deadline := time.Now().Add(5 * time.Second); !cond(); {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func titles(items []Item) []string {
+	var ts []string
+	for _, it := range items {
+		ts = append(ts, it.Title)
+	}
+	return ts
+}
+
+func TestScenarioDeliversEachEntryOnce(t *testing.T) {
+	// Each response repeats the last entry of the one before
+	srv := newFeedServer(t, func(n int64, w http.ResponseWriter, r *http.Request) {
+		entries(w, int(n), int(n+1))
+	})
+	noLeaks(t, func() {
+		s := Subscribe(srv.fetcher(t, "5ms"))
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		items, err := CollectN(ctx, s, 6) // and closes s
+		if err != nil {
+			t.Fatal(err)
+		}
+		seen := make(map[string]bool)
+		for i, it := range items {
+			if want := fmt.Sprintf("Entry %d", i+1); it.Title != want {
+				t.Errorf("item %d is %q, want %q", i, it.Title, want)
+			}
+			if seen[it.GUID] {
+				t.Errorf("%s delivered twice", it.GUID)
+			}
+			seen[it.GUID] = true
+		}
+	})
+}
+
+func TestScenarioMergedFeedsDedup(t *testing.T) {
+	// Two mirrors of the same channel, one ahead of the other
+	a := newFeedServer(t, func(n int64, w http.ResponseWriter, r *http.Request) {
+		entries(w, 1, 2, 3)
+	})
+	b := newFeedServer(t, func(n int64, w http.ResponseWriter, r *http.Request) {
+		entries(w, 2, 3, 4)
+	})
+	noLeaks(t, func() {
+		d := Dedup(Merge(Subscribe(a.fetcher(t, "5ms")), Subscribe(b.fetcher(t, "5ms"))), ByGUID, 0)
+		var items []Item
+		for len(items) < 4 {
+			select {
+			case it := <-d.Updates():
+				items = append(items, it)
+			case <-time.After(5 * time.Second):
+				t.Fatalf("only got %v", titles(items))
+			}
+		}
+		// Both servers keep being polled; nothing new may come of it
+		eventually(t, "more polls", func() bool { return a.requests.Load() > 5 && b.requests.Load() > 5 })
+		if stats := d.Stats(); stats.Delivered != 4 || stats.Dropped != 2 {
+			t.Errorf("delivered %d and dropped %d items, want 4 and 2", stats.Delivered, stats.Dropped)
+		}
+		if err := d.Close(); err != nil {
+			t.Errorf("Close: %v", err)
+		}
+	})
+}
+
+func TestScenarioServerErrorBacksOff(t *testing.T) {
+	srv := newFeedServer(t, func(n int64, w http.ResponseWriter, r *http.Request) {
+		if n == 1 {
+			http.Error(w, "down for maintenance", http.StatusServiceUnavailable)
+			return
+		}
+		entries(w, 1)
+	})
+	noLeaks(t, func() {
+		clock := NewFakeClock(time.Now())
+		s := SubscribeClock(srv.fetcher(t, "1m"), clock)
+
+		eventually(t, "the first fetch to fail", func() bool {
+			return srv.requests.Load() == 1 && clock.Timers() == 1
+		})
+		clock.Advance(9 * time.Second)
+		time.Sleep(20 * time.Millisecond)
+		if n := srv.requests.Load(); n != 1 {
+			t.Fatalf("fetched again %d times within the 10s backoff", n-1)
+		}
+		clock.Advance(time.Second)
+		eventually(t, "the retry", func() bool { return srv.requests.Load() == 2 })
+		if it := <-s.Updates(); it.Title != "Entry 1" {
+			t.Errorf("got %q after the retry, want Entry 1", it.Title)
+		}
+		if err := s.Close(); err != nil {
+			t.Errorf("Close after a good fetch returned %v", err)
+		}
+	})
+}
+
+func TestScenarioRateLimitedIsNotAnError(t *testing.T) {
+	srv := newFeedServer(t, func(n int64, w http.ResponseWriter, r *http.Request) {
+		if n == 1 {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		entries(w, 1)
+	})
+	noLeaks(t, func() {
+		s := Subscribe(srv.fetcher(t, "1h"))
+		select {
+		case it := <-s.Updates():
+			if it.Title != "Entry 1" {
+				t.Errorf("got %q, want Entry 1", it.Title)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("no item after the rate limit passed")
+		}
+		if err := s.Close(); err != nil {
+			t.Errorf("Close: %v, want no error for a 429", err)
+		}
+	})
+}
+
+func TestScenarioMalformedFeed(t *testing.T) {
+	srv := newFeedServer(t, func(n int64, w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"items": [{"id": 1, "title": "Entry 1"}`)) // cut short
+	})
+	noLeaks(t, func() {
+		s := Subscribe(srv.fetcher(t, "1h"))
+		eventually(t, "the fetch", func() bool { return srv.requests.Load() == 1 })
+		time.Sleep(20 * time.Millisecond)
+		if err := s.Close(); err == nil {
+			t.Error("Close returned no error for a truncated feed")
+		}
+	})
+}
+
+func TestScenarioCloseCancelsHungFetch(t *testing.T) {
+	release := make(chan struct{})
+	srv := newFeedServer(t, func(n int64, w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-release:
+		}
+	})
+	defer close(release) // before the server's Close waits for us
+	noLeaks(t, func() {
+		s := Subscribe(srv.fetcher(t, "1h"))
+		eventually(t, "the fetch", func() bool { return srv.requests.Load() == 1 })
+
+		start := time.Now()
+		err := s.Close()
+		if d := time.Since(start); d > time.Second {
+			t.Errorf("Close took %v with a fetch hung on the server", d)
+		}
+		if !errors.Is(err, context.Canceled) && err != nil {
+			t.Errorf("Close: %v", err)
+		}
+	})
+}
+
+func TestScenarioScriptedShutdownDrains(t *testing.T) {
+	noLeaks(t, func() {
+		clock := NewFakeClock(time.Now())
+		items := []Item{{GUID: "a", Title: "A"}, {GUID: "b", Title: "B"}, {GUID: "c", Title: "C"}}
+		f := NewScriptedFetcher(clock, Response{Items: items, Next: time.Hour})
+		s := SubscribeClock(f, clock).(*sub)
+		eventually(t, "the fetch", func() bool { return s.Pending().Count == 3 })
+
+		first := <-s.Updates()
+		rest, err := s.CloseAndDrain()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := titles(append([]Item{first}, rest...)); fmt.Sprint(got) != "[A B C]" {
+			t.Errorf("delivered and drained %v, want [A B C]", got)
+		}
+		if _, ok := <-s.Updates(); ok {
+			t.Error("Updates still open after CloseAndDrain")
+		}
+	})
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// Scheduler wakes subscriptions when their next fetch is due from a
+// single timer wheel, instead of every subscription keeping a timer of
+// its own. The wheel has slots buckets, one per tick; a wakeup more
+// than a turn away waits in its bucket for the rounds it still needs.
+// Wakeups fire up to a tick after they are due, so tick is the
+// scheduler's resolution.
+type Scheduler struct {
+	tick  time.Duration
+	slots [][]*wakeup
+	add   chan *wakeup
+	quit  chan struct{}
+	once  sync.Once // for Stop
+}
+
+type wakeup struct {
+	at     time.Time
+	c      chan time.Time // buffered, so firing never blocks the wheel
+	rounds int            // full turns left before it fires
+}
+
+// NewScheduler starts a wheel turning every tick.
+func NewScheduler(tick time.Duration, slots int) *Scheduler {
+	s := &Scheduler{
+		tick:  tick,
+		slots: make([][]*wakeup, max(slots, 1)),
+		add:   make(chan *wakeup),
+		quit:  make(chan struct{}),
+	}
+	go s.loop()
+	return s
+}
+
+// At returns a channel that receives the time once t has passed. A
+// wakeup nobody waits for any more just fires into its channel and is
+// forgotten. Once the scheduler is stopped, At falls back to
+// time.After.
+func (s *Scheduler) At(t time.Time) <-chan time.Time {
+	w := &wakeup{at: t, c: make(chan time.Time, 1)}
+	select {
+	case s.add <- w:
+		return w.c
+	case <-s.quit:
+		return time.After(time.Until(t))
+	}
+}
+
+// Stop stops the wheel. Wakeups still pending are handed to timers of
+// their own, so they fire when due as if the scheduler had not been
+// stopped. It can be called more than once.
+func (s *Scheduler) Stop() {
+	s.once.Do(func() { close(s.quit) })
+}
+
+func (s *Scheduler) loop() {
+	ticker := time.NewTicker(s.tick)
+	defer ticker.Stop()
+	pos := 0
+
+	for {
+		select {
+		case w := <-s.add:
+			d := time.Until(w.at)
+			if d <= 0 {
+				w.c <- time.Now()
+				break
+			}
+			// The next tick may be only moments away, so count one more
+			ticks := int((d+s.tick-1)/s.tick) + 1
+			slot := (pos + ticks) % len(s.slots)
+			w.rounds = (ticks - 1) / len(s.slots)
+			s.slots[slot] = append(s.slots[slot], w)
+		case now := <-ticker.C:
+			pos = (pos + 1) % len(s.slots)
+			waiting := s.slots[pos][:0]
+			for _, w := range s.slots[pos] {
+				if w.rounds > 0 {
+					w.rounds--
+					waiting = append(waiting, w)
+					continue
+				}
+				w.c <- now
+			}
+			clear(s.slots[pos][len(waiting):])
+			s.slots[pos] = waiting
+		case <-s.quit:
+			for _, slot := range s.slots {
+				for _, w := range slot {
+					time.AfterFunc(time.Until(w.at), func() { w.c <- time.Now() })
+				}
+			}
+			return
+		}
+	}
+}
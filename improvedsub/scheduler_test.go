@@ -0,0 +1,22 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSchedulerStopFiresPendingWakeups(t *testing.T) {
+	s := NewScheduler(10*time.Millisecond, 4)
+	soon := s.At(time.Now().Add(50 * time.Millisecond))
+	later := s.At(time.Now().Add(time.Second)) // more than a turn away
+	s.Stop()
+	s.Stop() // again, as deferred cleanups tend to
+
+	for _, c := range []<-chan time.Time{soon, later} {
+		select {
+		case <-c:
+		case <-time.After(5 * time.Second):
+			t.Fatal("wakeup pending at Stop never fired")
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// Response is one scripted answer of a ScriptedFetcher. Next is
+// relative to the time of the fetch, so scripts don't depend on when
+// they run; zero means fetch again right away.
+type Response struct {
+	Items   []Item
+	Next    time.Duration
+	Err     error
+	Latency time.Duration // how long the fetch takes
+}
+
+// FetchCall records one call to a ScriptedFetcher.
+type FetchCall struct {
+	At       time.Time // when the fetch started
+	Response int       // index of the response given, -1 if none was left
+}
+
+// ErrScriptDone is returned by a ScriptedFetcher that has run out of
+// responses.
+var ErrScriptDone = errors.New("scripted fetcher: no more responses")
+
+// ScriptedFetcher is a Fetcher for tests that answers with exactly the
+// responses queued on it, in order, and records every call so tests
+// can check how often and when the loop fetched. Latency is waited out
+// on its Clock, so with a FakeClock the test decides when a fetch
+// returns. Fetches made after the script ran out fail with
+// ErrScriptDone and come back an hour later.
+type ScriptedFetcher struct {
+	mu        sync.Mutex
+	clock     Clock
+	responses []Response
+	calls     []FetchCall
+	given     int
+}
+
+// NewScriptedFetcher returns a ScriptedFetcher that will answer with
+// responses, timed by clock.
+func NewScriptedFetcher(clock Clock, responses ...Response) *ScriptedFetcher {
+	return &ScriptedFetcher{clock: clock, responses: responses}
+}
+
+// Enqueue adds responses to the end of the script.
+func (f *ScriptedFetcher) Enqueue(responses ...Response) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.responses = append(f.responses, responses...)
+}
+
+// Calls returns the calls made so far.
+func (f *ScriptedFetcher) Calls() []FetchCall {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]FetchCall(nil), f.calls...)
+}
+
+func (f *ScriptedFetcher) Fetch() (items []Item, next time.Time, err error) {
+	return f.FetchContext(context.Background())
+}
+
+// FetchContext gives up waiting out the latency once ctx is done.
+func (f *ScriptedFetcher) FetchContext(ctx context.Context) (items []Item, next time.Time, err error) {
+	f.mu.Lock()
+	call := FetchCall{At: f.clock.Now(), Response: -1}
+	r := Response{Err: ErrScriptDone, Next: time.Hour}
+	if f.given < len(f.responses) {
+		call.Response = f.given
+		r = f.responses[f.given]
+		f.given++
+	}
+	f.calls = append(f.calls, call)
+	f.mu.Unlock()
+
+	if r.Latency > 0 {
+		select {
+		case <-f.clock.After(r.Latency):
+		case <-ctx.Done():
+			return nil, time.Time{}, ctx.Err()
+		}
+	}
+	return r.Items, f.clock.Now().Add(r.Next), r.Err
+}
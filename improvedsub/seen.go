@@ -0,0 +1,19 @@
+package main
+
+// SeenStore remembers the keys of items that were already delivered,
+// usually their GUIDs.
+type SeenStore interface {
+	// Add records key and reports whether it was new.
+	Add(key string) bool
+}
+
+// seenMap is the default SeenStore: exact, and never forgets.
+type seenMap map[string]bool
+
+func (m seenMap) Add(key string) bool {
+	if m[key] {
+		return false
+	}
+	m[key] = true
+	return true
+}
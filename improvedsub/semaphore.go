@@ -0,0 +1,134 @@
+package main
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+)
+
+// Semaphore is a weighted semaphore: Acquire takes n units out of a
+// fixed capacity, blocking until they are free. Waiters are served in
+// order, so a large request is not starved by a stream of small ones.
+type Semaphore struct {
+	mu      sync.Mutex
+	size    int64
+	cur     int64
+	waiters list.List // of *semWaiter
+}
+
+type semWaiter struct {
+	n     int64
+	ready chan struct{} // closed when the units are granted
+}
+
+// NewSemaphore returns a Semaphore with n units.
+func NewSemaphore(n int64) *Semaphore {
+	return &Semaphore{size: n}
+}
+
+// Acquire takes n units, blocking until they are available or ctx is
+// done. On error nothing is taken. More units than the semaphore has
+// can never be granted: such a request just waits for ctx, without
+// queueing, so the waiters behind it are not held up forever.
+func (s *Semaphore) Acquire(ctx context.Context, n int64) error {
+	s.mu.Lock()
+	if s.size-s.cur >= n && s.waiters.Len() == 0 {
+		s.cur += n
+		s.mu.Unlock()
+		return nil
+	}
+	if n > s.size {
+		s.mu.Unlock()
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	w := &semWaiter{n: n, ready: make(chan struct{})}
+	e := s.waiters.PushBack(w)
+	s.mu.Unlock()
+
+	select {
+	case <-w.ready:
+		return nil
+	case <-ctx.Done():
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		select {
+		case <-w.ready: // granted while we were giving up: hand it back
+			s.cur -= n
+			s.notify()
+		default:
+			front := s.waiters.Front() == e
+			s.waiters.Remove(e)
+			if front {
+				s.notify() // smaller waiters behind us may fit now
+			}
+		}
+		return ctx.Err()
+	}
+}
+
+// TryAcquire takes n units if they are available right away.
+func (s *Semaphore) TryAcquire(n int64) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.size-s.cur >= n && s.waiters.Len() == 0 {
+		s.cur += n
+		return true
+	}
+	return false
+}
+
+// Release returns n units.
+func (s *Semaphore) Release(n int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.cur -= n
+	if s.cur < 0 {
+		panic("semaphore: released more than held")
+	}
+	s.notify()
+}
+
+// notify grants units to waiters in order, as long as the first one
+// fits.
+func (s *Semaphore) notify() {
+	for {
+		e := s.waiters.Front()
+		if e == nil {
+			return
+		}
+		w := e.Value.(*semWaiter)
+		if s.size-s.cur < w.n {
+			return
+		}
+		s.cur += w.n
+		s.waiters.Remove(e)
+		close(w.ready)
+	}
+}
+
+// LimitFetches returns a Fetcher that holds one unit of sem for each
+// Fetch of f, so fetchers sharing sem never run more fetches at once
+// than it has units.
+func LimitFetches(f Fetcher, sem *Semaphore) Fetcher {
+	return &limitedFetcher{fetcher: f, sem: sem}
+}
+
+type limitedFetcher struct {
+	fetcher Fetcher
+	sem     *Semaphore
+}
+
+func (f *limitedFetcher) Fetch() (items []Item, next time.Time, err error) {
+	return f.FetchContext(context.Background())
+}
+
+// FetchContext also stops waiting for sem once ctx is done.
+func (f *limitedFetcher) FetchContext(ctx context.Context) (items []Item, next time.Time, err error) {
+	if err := f.sem.Acquire(ctx, 1); err != nil {
+		return nil, time.Time{}, err
+	}
+	defer f.sem.Release(1)
+	return fetchContext(ctx, f.fetcher)
+}
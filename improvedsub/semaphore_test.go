@@ -0,0 +1,38 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestSemaphoreOversizedAcquireDoesNotBlockOthers(t *testing.T) {
+	sem := NewSemaphore(2)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if err := sem.Acquire(ctx, 2); err != nil {
+		t.Fatal(err)
+	}
+
+	big := make(chan error, 1)
+	go func() { big <- sem.Acquire(ctx, 3) }()
+	time.Sleep(10 * time.Millisecond) // let it wait first
+	small := make(chan error, 1)
+	go func() { small <- sem.Acquire(ctx, 1) }()
+
+	sem.Release(2)
+	select {
+	case err := <-small:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("a request that fits waits behind one that never can")
+	}
+
+	cancel()
+	if err := <-big; !errors.Is(err, context.Canceled) {
+		t.Fatalf("oversized Acquire = %v; want context.Canceled", err)
+	}
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// BlockStore reserves numbers for a Sequence in blocks, so the backing
+// store is written once per block rather than once per number. It
+// must never hand out the same number twice, including across
+// restarts; a store shared by several processes must also make
+// Reserve atomic between them.
+type BlockStore interface {
+	// Reserve returns the first of n consecutive numbers no one else
+	// has been given.
+	Reserve(n uint64) (first uint64, err error)
+}
+
+// Sequence hands out increasing numbers that stay increasing across
+// restarts, as long as its BlockStore persists what it reserved. The
+// unused rest of a block is skipped after a restart, so numbers are
+// monotonic but not contiguous.
+type Sequence struct {
+	mu    sync.Mutex
+	store BlockStore
+	block uint64
+	next  uint64 // next number to hand out
+	limit uint64 // first number not reserved yet
+}
+
+// NewSequence returns a Sequence reserving block numbers at a time
+// from store.
+func NewSequence(store BlockStore, block uint64) *Sequence {
+	return &Sequence{store: store, block: max(block, 1)}
+}
+
+// Next returns the next number. It only fails when a new block is
+// needed and store cannot reserve it, in which case later calls try
+// again.
+func (s *Sequence) Next() (uint64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.next == s.limit {
+		first, err := s.store.Reserve(s.block)
+		if err != nil {
+			return 0, err
+		}
+		s.next, s.limit = first, first+s.block
+	}
+	n := s.next
+	s.next++
+	return n, nil
+}
+
+const seqMagic = "SEQBLOK1"
+
+// FileBlocks is the default BlockStore: the high-water mark of what was
+// reserved, kept in a small file. Each Reserve writes the new mark to
+// a temporary file, syncs it, renames it over the old one and syncs
+// the directory, so a crash leaves either the old mark or the new one,
+// never a torn file. Numbers start at 1. It is meant for a single
+// process.
+type FileBlocks struct {
+	mu   sync.Mutex
+	path string
+}
+
+// NewFileBlocks returns a FileBlocks keeping its mark at path. The file
+// is created on the first Reserve.
+func NewFileBlocks(path string) *FileBlocks {
+	return &FileBlocks{path: path}
+}
+
+func (b *FileBlocks) Reserve(n uint64) (uint64, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	mark, err := b.read()
+	if err != nil {
+		return 0, err
+	}
+	if err := b.write(mark + n); err != nil {
+		return 0, err
+	}
+	return mark + 1, nil
+}
+
+// read returns the last number reserved, 0 if none was.
+func (b *FileBlocks) read() (uint64, error) {
+	data, err := os.ReadFile(b.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	if len(data) != 16 || string(data[:8]) != seqMagic {
+		return 0, fmt.Errorf("sequence file %s: not a sequence file", b.path)
+	}
+	return binary.LittleEndian.Uint64(data[8:]), nil
+}
+
+func (b *FileBlocks) write(mark uint64) error {
+	data := make([]byte, 16)
+	copy(data, seqMagic)
+	binary.LittleEndian.PutUint64(data[8:], mark)
+	return writeFileAtomic(b.path, data)
+}
+
+// writeFileAtomic replaces the file at path with data by writing a
+// temporary file, syncing it and renaming it over the old one, so a
+// crash leaves either the old contents or the new ones. The directory
+// is synced too, or the rename itself could be lost.
+func writeFileAtomic(path string, data []byte) error {
+	tmp := path + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o644)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(data)
+	if err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp, path)
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return syncDir(filepath.Dir(path))
+}
+
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	err = d.Sync()
+	if cerr := d.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
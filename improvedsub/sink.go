@@ -0,0 +1,35 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+)
+
+// Sink is somewhere items end up: a log, a webhook, a message bus.
+type Sink interface {
+	Send(Item) error
+}
+
+// SinkFunc adapts a function to the Sink interface.
+type SinkFunc func(Item) error
+
+func (f SinkFunc) Send(it Item) error {
+	return f(it)
+}
+
+// WriterSink returns a Sink writing each item to w as a line of JSON.
+func WriterSink(w io.Writer) Sink {
+	return &writerSink{enc: json.NewEncoder(w)}
+}
+
+type writerSink struct {
+	mu  sync.Mutex // Send may be called from several goroutines
+	enc *json.Encoder
+}
+
+func (s *writerSink) Send(it Item) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.enc.Encode(it)
+}
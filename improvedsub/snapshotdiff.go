@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"hash/fnv"
+	"sync"
+	"time"
+)
+
+// DiffSnapshots returns a Fetcher that remembers what f returned last
+// time and passes on only the entries that were not in it, identified
+// by key. It suits feeds that always return their latest N entries
+// and have neither stable GUIDs nor conditional GET: key can then be
+// ByContent. Memory is bounded by one snapshot, unlike the seen map
+// of a subscription, which keeps far less to dedup as a result. A
+// fingerprint of the whole snapshot serves as its ETag, so an
+// unchanged feed is recognized without comparing entries.
+func DiffSnapshots(f Fetcher, key func(Item) string) Fetcher {
+	return &snapshotFetcher{fetcher: f, key: key}
+}
+
+type snapshotFetcher struct {
+	fetcher Fetcher
+	key     func(Item) string
+
+	mu   sync.Mutex
+	etag uint64
+	last map[string]bool // keys of the last snapshot, nil before the first
+}
+
+func (f *snapshotFetcher) Fetch() (items []Item, next time.Time, err error) {
+	return f.FetchContext(context.Background())
+}
+
+func (f *snapshotFetcher) FetchContext(ctx context.Context) (items []Item, next time.Time, err error) {
+	items, next, err = fetchContext(ctx, f.fetcher)
+	if err != nil {
+		return nil, next, err // keep the last snapshot to diff against
+	}
+
+	keys := make([]string, len(items))
+	h := fnv.New64a()
+	for i, it := range items {
+		keys[i] = f.key(it)
+		h.Write([]byte(keys[i]))
+		h.Write([]byte{0})
+	}
+	etag := h.Sum64()
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.last != nil && etag == f.etag {
+		return nil, next, nil
+	}
+	fresh := items[:0:0]
+	current := make(map[string]bool, len(items))
+	for i, it := range items {
+		current[keys[i]] = true
+		if !f.last[keys[i]] {
+			fresh = append(fresh, it)
+		}
+	}
+	f.etag, f.last = etag, current
+	return fresh, next, nil
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// SSEHandler serves the items of b as Server-Sent Events. Every
+// connection gets a reader of its own, so each client has its own
+// buffer and b's SlowReaderPolicy decides what happens when a client
+// falls behind; with Block, one stalled client holds up the others.
+// Each item is sent as an event with its GUID as id and the item as
+// JSON data. A client that disconnects closes just its reader. A
+// comment line is written every keepalive, if positive, so proxies
+// don't time out a quiet stream.
+func SSEHandler(b *Broadcast, keepalive time.Duration) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Join before the client sees the response, so no item
+		// broadcast after that is missed
+		sub := b.NewReader()
+		defer sub.Close()
+
+		rc := http.NewResponseController(w)
+		h := w.Header()
+		h.Set("Content-Type", "text/event-stream")
+		h.Set("Cache-Control", "no-cache")
+		w.WriteHeader(http.StatusOK)
+		if err := rc.Flush(); err != nil {
+			return // can't stream on this connection
+		}
+
+		var tick <-chan time.Time
+		if keepalive > 0 {
+			ticker := time.NewTicker(keepalive)
+			defer ticker.Stop()
+			tick = ticker.C
+		}
+
+		for {
+			var err error
+			select {
+			case it, ok := <-sub.Updates():
+				if !ok {
+					return // the broadcast was closed, or its subscription ended
+				}
+				err = writeSSE(w, it)
+			case <-tick:
+				_, err = fmt.Fprint(w, ": keepalive\n\n")
+			case <-r.Context().Done():
+				return
+			}
+			if err == nil {
+				err = rc.Flush()
+			}
+			if err != nil {
+				return
+			}
+		}
+	})
+}
+
+func writeSSE(w http.ResponseWriter, it Item) error {
+	data, err := json.Marshal(it)
+	if err != nil {
+		return err
+	}
+	id := strings.NewReplacer("\n", "", "\r", "").Replace(it.GUID) // would end the field
+	_, err = fmt.Fprintf(w, "id: %s\ndata: %s\n\n", id, data)
+	return err
+}
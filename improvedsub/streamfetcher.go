@@ -0,0 +1,100 @@
+package main
+
+// StreamFetcher is a push-style source: instead of being polled for
+// items, it sends them on items as they happen until done is closed.
+// Stream returns the error, if any, that stopped the stream.
+type StreamFetcher interface {
+	Stream(done <-chan struct{}, items chan<- Item) error
+}
+
+// returns a new Subscription delivering the Items pushed by fetcher.
+func SubscribeStream(fetcher StreamFetcher) Subscription {
+	s := &streamSub{
+		fetcher: fetcher,
+		updates: make(chan Item),
+		closing: make(chan chan error),
+		done:    make(chan struct{}),
+	}
+	go s.loop()
+	return s
+}
+
+// streamSub adapts a StreamFetcher to the Subscription interface
+type streamSub struct {
+	fetcher StreamFetcher   // pushes Items
+	updates chan Item       // delivers Items to the user
+	closing chan chan error // for Close
+	done    chan struct{}   // closed when loop returns
+	err     error           // the stream's error, set before done
+}
+
+func (s *streamSub) Updates() <-chan Item {
+	return s.updates
+}
+
+func (s *streamSub) Close() error {
+	errc := make(chan error)
+	select {
+	case s.closing <- errc:
+		return <-errc
+	case <-s.done:
+		return s.err
+	}
+}
+
+// loop is the push counterpart of sub.loop: the stream goroutine takes
+// the place of fetchDone, and it is only read from while pending has room.
+// Once Stream has returned and pending is delivered, Updates is closed
+// and Close returns the stream's error.
+func (s *streamSub) loop() {
+	defer close(s.done)
+
+	const maxPending = 10
+	done := make(chan struct{})
+	items := make(chan Item)
+	streamDone := make(chan error, 1)
+	go func() {
+		streamDone <- s.fetcher.Stream(done, items)
+	}()
+
+	var pending []Item
+	var err error
+
+	for {
+		if streamDone == nil && len(pending) == 0 {
+			s.err = err // the stream ended on its own
+			close(s.updates)
+			return
+		}
+
+		var received chan Item
+		if len(pending) < maxPending {
+			received = items
+		}
+
+		var first Item
+		var updates chan Item
+		if len(pending) > 0 {
+			first = pending[0]
+			updates = s.updates
+		}
+
+		select {
+		case errc := <-s.closing:
+			close(done)
+			if streamDone != nil {
+				err = <-streamDone
+			}
+			s.err = err
+			errc <- err
+			close(s.updates)
+			return
+		case err = <-streamDone:
+			streamDone = nil
+		case item := <-received:
+			pending = append(pending, item)
+		case updates <- first:
+			pending = pending[1:]
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestStreamSubEndsWithItsStream(t *testing.T) {
+	AssertNoLeaks(t, func() {
+		dir := t.TempDir()
+		if err := os.WriteFile(filepath.Join(dir, "a"), nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		s := SubscribeStream(WatchDir(dir, 10*time.Millisecond))
+		select {
+		case it := <-s.Updates():
+			if it.Title != "a" {
+				t.Fatalf("got %q; want a", it.Title)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("no item for a")
+		}
+
+		// ReadDir fails from the next scan on, which ends the stream
+		if err := os.RemoveAll(dir); err != nil {
+			t.Fatal(err)
+		}
+		select {
+		case it, ok := <-s.Updates():
+			if ok {
+				t.Fatalf("got %q after the directory was removed", it.Title)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("Updates still open after the stream ended")
+		}
+		if err := s.Close(); err == nil {
+			t.Error("Close = nil; want the ReadDir error")
+		}
+	})
+}
+
+func TestWatchDirReportsRecreatedFile(t *testing.T) {
+	AssertNoLeaks(t, func() {
+		dir := t.TempDir()
+		path := filepath.Join(dir, "a")
+		mod := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+		create := func() {
+			t.Helper()
+			if err := os.WriteFile(path, nil, 0o644); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.Chtimes(path, mod, mod); err != nil {
+				t.Fatal(err)
+			}
+		}
+		next := func(s Subscription) {
+			t.Helper()
+			select {
+			case <-s.Updates():
+			case <-time.After(5 * time.Second):
+				t.Fatal("no item for a")
+			}
+		}
+
+		create()
+		s := SubscribeStream(WatchDir(dir, 10*time.Millisecond))
+		defer s.Close()
+		next(s)
+		if err := os.Remove(path); err != nil {
+			t.Fatal(err)
+		}
+		time.Sleep(50 * time.Millisecond) // a few scans without it
+		create()                          // same name, same modification time
+		next(s)
+	})
+}
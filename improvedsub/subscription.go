@@ -1,15 +1,106 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime/pprof"
+	"runtime/trace"
 	"time"
 )
 
 // returns a new Subscription using Fetcher to fetch Items.
 func Subscribe(fetcher Fetcher) Subscription {
+	return newSub(fetcher, subOptions{})
+}
+
+// SubscribeFor is like Subscribe, but the subscription only fetches
+// for d. After that it delivers what is still pending and closes
+// Updates on its own, turning the stream into a finite batch run.
+func SubscribeFor(fetcher Fetcher, d time.Duration) Subscription {
+	return newSub(fetcher, subOptions{window: d})
+}
+
+// SubscribeUntil is like SubscribeFor, with the window ending at t.
+func SubscribeUntil(fetcher Fetcher, t time.Time) Subscription {
+	return newSub(fetcher, subOptions{until: t})
+}
+
+// SubscribeSeen is like Subscribe, but remembers delivered GUIDs in
+// seen instead of an in-memory map, for feeds where the map would grow
+// too large. A store shared between subscriptions dedups across them.
+func SubscribeSeen(fetcher Fetcher, seen SeenStore) Subscription {
+	return newSub(fetcher, subOptions{seen: seen})
+}
+
+// HeartbeatSubscription is a Subscription that also reports that its
+// loop is alive.
+type HeartbeatSubscription interface {
+	Subscription
+	// Heartbeats receives a pulse every interval and before each
+	// fetch, whether or not there are new items, so a supervisor can
+	// tell a quiet feed from a wedged loop. Pulses nobody reads are
+	// dropped. The channel is closed when the subscription ends.
+	Heartbeats() <-chan time.Time
+}
+
+// SubscribeHeartbeat is like Subscribe, with heartbeats every interval.
+func SubscribeHeartbeat(fetcher Fetcher, interval time.Duration) HeartbeatSubscription {
+	return newSub(fetcher, subOptions{interval: interval})
+}
+
+// SubscribeScheduled is like Subscribe, but its fetches are timed by
+// sched, which many subscriptions can share.
+func SubscribeScheduled(fetcher Fetcher, sched *Scheduler) Subscription {
+	return newSub(fetcher, subOptions{sched: sched})
+}
+
+// SubscribeClock is like Subscribe, with the loop's time and timers
+// taken from clock, which in tests is typically a FakeClock.
+func SubscribeClock(fetcher Fetcher, clock Clock) Subscription {
+	return newSub(fetcher, subOptions{clock: clock})
+}
+
+// subOptions are the variations on Subscribe; the zero value is plain
+// Subscribe.
+type subOptions struct {
+	seen     SeenStore
+	window   time.Duration // fetch for this long, if set
+	until    time.Time     // fetch until then, if set
+	interval time.Duration
+	sched    *Scheduler
+	clock    Clock
+
+	feed        string // the name checkpoints and expvars are kept under
+	checkpoints Checkpointer
+	vars        *subVars // see SubscribeExpvar
+}
+
+func newSub(fetcher Fetcher, opts subOptions) *sub {
+	if opts.seen == nil {
+		opts.seen = make(seenMap)
+	}
+	if opts.clock == nil {
+		opts.clock = RealClock
+	}
 	s := &sub{
-		fetcher: fetcher,
-		updates: make(chan Item),
-		closing: make(chan chan error),
+		fetcher:   fetcher,
+		seen:      opts.seen,
+		updates:   make(chan Item),
+		mailbox:   NewMailbox[subState](),
+		interval:  opts.interval,
+		sched:     opts.sched,
+		clock:     opts.clock,
+		heartbeat: make(chan time.Time, 1),
+		feed:      opts.feed,
+		cps:       opts.checkpoints,
+		vars:      opts.vars,
+	}
+	switch {
+	case opts.window > 0:
+		s.deadline = s.clock.After(opts.window)
+	case !opts.until.IsZero():
+		s.deadline = s.clock.After(opts.until.Sub(s.clock.Now()))
 	}
 	go s.loop()
 	return s
@@ -17,83 +108,363 @@ func Subscribe(fetcher Fetcher) Subscription {
 
 // sub implements the subscription interface
 type sub struct {
-	fetcher Fetcher         // fetches Items
-	updates chan Item       // delivers Items to the user
-	closing chan chan error // for Close
+	fetcher   Fetcher            // fetches Items
+	seen      SeenStore          // GUIDs already queued
+	updates   chan Item          // delivers Items to the user
+	mailbox   *Mailbox[subState] // for Close and Pending
+	deadline  <-chan time.Time   // stops fetching, nil if never
+	interval  time.Duration      // between heartbeats, 0 for fetches only
+	sched     *Scheduler         // times fetches, nil for a timer of our own
+	clock     Clock              // for everything else to do with time
+	heartbeat chan time.Time     // for Heartbeats
+	feed      string             // see SubscribeCheckpointed
+	cps       Checkpointer       // nil if not checkpointed
+	vars      *subVars           // nil if not published
+	err       error              // last fetch error, set before the mailbox stops
+}
+
+// subState is what sub.loop owns and commands may look at.
+type subState struct {
+	pending ring[queued] // with when each item was fetched
+	err     error        // last fetch error
+	closed  bool         // set by Close
 }
 
 func (s *sub) Updates() <-chan Item {
 	return s.updates
 }
 
+func (s *sub) Heartbeats() <-chan time.Time {
+	return s.heartbeat
+}
+
+// Pending reports on the items fetched but not yet delivered, or the
+// zero value once the subscription has ended.
+func (s *sub) Pending() PendingStats {
+	st, _ := Ask(s.mailbox, func(st *subState) PendingStats {
+		return newPendingStats(&st.pending, s.clock.Now())
+	})
+	return st
+}
+
+// beat sends a heartbeat unless the last one is still unread.
+func (s *sub) beat() {
+	select {
+	case s.heartbeat <- s.clock.Now():
+	default:
+	}
+}
+
+// Close also works once the subscription has ended on its own, in
+// which case it returns the error it ended with. A fetch in flight is
+// cancelled if the Fetcher is a ContextFetcher, and Close returns only
+// once it is over.
 func (s *sub) Close() error {
-	errc := make(chan error)
-	s.closing <- errc
-	return <-errc
+	return s.CloseContext(context.Background())
 }
 
-// mergedLoop: it combines loopFetchOnly, loopSendOnly
-// and loopCloseOnly
+// CloseContext is like Close, but gives up once ctx is done, returning
+// an ErrCloseTimeout. The subscription is then closed in the
+// background.
+func (s *sub) CloseContext(ctx context.Context) error {
+	err, askErr := AskContext(ctx, s.mailbox, func(st *subState) error {
+		st.closed = true
+		return st.err
+	})
+	switch {
+	case errors.Is(askErr, ErrStopped):
+		return s.err
+	case askErr != nil:
+		go s.Close()
+		return fmt.Errorf("%w: %w", ErrCloseTimeout, askErr)
+	}
+	select {
+	case <-s.mailbox.Done():
+		return err
+	case <-ctx.Done():
+		return fmt.Errorf("%w: %w", ErrCloseTimeout, ctx.Err())
+	}
+}
+
+// CloseAndDrain is like Close, but also returns the items that were
+// fetched and not yet delivered, oldest first, so the caller can keep
+// them instead of losing them. A fetch still in flight is cancelled,
+// and CloseAndDrain waits for it to return, as Close does; whatever it
+// fetched is not among the items returned. Once the subscription has
+// ended there is nothing left to drain.
+func (s *sub) CloseAndDrain() ([]Item, error) {
+	type drained struct {
+		items []Item
+		err   error
+	}
+	d, askErr := Ask(s.mailbox, func(st *subState) drained {
+		st.closed = true
+		items := make([]Item, 0, st.pending.len())
+		for st.pending.len() > 0 {
+			items = append(items, st.pending.pop().it)
+		}
+		return drained{items, st.err}
+	})
+	if askErr != nil {
+		return nil, s.err
+	}
+	<-s.mailbox.Done()
+	return d.items, d.err
+}
+
+// loop owns the subscription's state. It is still the merged loop of
+// the talk, fetching, delivering and closing in one select, with
+// pending bounded and deduplicated through seen, fetches run off the
+// loop goroutine and commands arriving through the mailbox. Tracing,
+// profiling labels, expvars, heartbeats and checkpoints are kept in the
+// helpers below, each doing nothing when its option is not set.
 func (s *sub) loop() {
 
 	const maxPending = 10
-	type fetchResult struct {
-		fetched []Item
-		next    time.Time
-		err     error
-	}
-	var fetchDone chan fetchResult
+	ctx, cancel := context.WithCancel(s.labelLoop()) // for fetches
+	defer cancel()
 
-	var pending []Item
+	st := subState{pending: newRing[queued](maxPending)}
+	cps, err := s.loadCheckpoints()
+	st.err = err
+	defer s.vars.setPending(0)
+
+	var fetch *fetchCycle // in flight, nil if none
 	var next time.Time
-	var err error
-	var seen = make(map[string]bool)
+	var expired bool
+
+	timer := s.newFetchTimer()
+	defer timer.stop()
+	pulse := s.newPulse()
+	defer pulse.stop()
 
 	for {
-		var fetchDelay time.Duration
-		if now := time.Now(); next.After(now) {
-			fetchDelay = next.Sub(now)
+		s.vars.setPending(st.pending.len())
+
+		if st.closed || (expired && fetch == nil && st.pending.len() == 0) {
+			if fetch != nil {
+				cancel()
+				fetch.wait() // its result goes with the subscription
+			}
+			s.finish(st.err)
+			return
 		}
 
 		var startFetch <-chan time.Time
-		if fetchDone == nil && len(pending) < maxPending {
-			startFetch = time.After(fetchDelay)
+		if fetch == nil && st.pending.len() < maxPending && !expired {
+			startFetch = timer.arm(next)
+		} else {
+			timer.stop()
+		}
+		var fetchDone <-chan fetchResult
+		if fetch != nil {
+			fetchDone = fetch.done
 		}
 
 		var first Item
 		var updates chan Item
-		if len(pending) > 0 {
-			first = pending[0]
+		if st.pending.len() > 0 {
+			first = st.pending.at(0).it
 			updates = s.updates
 		}
 
 		select {
-		case errc := <-s.closing:
-			errc <- err
-			close(s.updates)
-			return
+		case e := <-s.mailbox.Inbox():
+			e.Run(&st)
+		case <-s.deadline:
+			expired = true
+		case <-pulse.c:
+			pulse.reset()
+			s.beat()
 		case <-startFetch:
-			fetchDone = make(chan fetchResult, 1)
-			go func() {
-				fetched, next, err := s.fetcher.Fetch()
-				fetchDone <- fetchResult{fetched, next, err}
-			}()
+			timer.fired()
+			s.beat()
+			fetch = s.startFetch(ctx)
 		case result := <-fetchDone:
-			fetchDone = nil
-			fetched := result.fetched
-			next, err = result.next, result.err
-			if err != nil {
-				next = time.Now().Add(10 * time.Second)
-				break
-			}
-			for _, item := range fetched {
-				if !seen[item.GUID] {
-					pending = append(pending, item)
-					seen[item.GUID] = true
-				}
+			switch {
+			case errors.Is(result.err, ErrRateLimited):
+				next = result.next // not a failure, just come back later
+			case result.err != nil:
+				s.vars.fetched(result.err)
+				next, st.err = s.clock.Now().Add(10*time.Second), result.err
+			default:
+				s.vars.fetched(nil)
+				next, st.err = result.next, nil
+				s.queue(fetch.ctx, &st, cps, result.fetched)
 			}
+			fetch.task.End()
+			fetch = nil
 		case updates <- first:
-			pending = pending[1:]
+			s.deliver(ctx, &st, cps)
+		}
+	}
+}
+
+// queue adds the fetched items not seen before, nor covered by the
+// checkpoint, to pending.
+func (s *sub) queue(ctx context.Context, st *subState, cps *loopCheckpoints, fetched []Item) {
+	defer trace.StartRegion(ctx, "dedup").End()
+	now := s.clock.Now()
+	for _, item := range fetched {
+		if !cps.skip(item) && s.seen.Add(item.GUID) {
+			st.pending.push(queued{item, now})
+			cps.add(item)
 		}
 	}
+	cps.mark(st.pending.len())
+}
+
+// deliver drops the first pending item once it has been sent.
+func (s *sub) deliver(ctx context.Context, st *subState, cps *loopCheckpoints) {
+	// The send itself was the select; the region is its aftermath.
+	defer trace.StartRegion(ctx, "deliver").End()
+	st.pending.pop()
+	s.vars.deliver()
+	if err := cps.deliver(); err != nil {
+		st.err = err
+	}
+}
+
+type fetchResult struct {
+	fetched []Item
+	next    time.Time
+	err     error
+}
+
+// fetchCycle is a fetch in flight, traced as a task from its start
+// until its items are queued.
+type fetchCycle struct {
+	ctx  context.Context
+	task *trace.Task
+	done chan fetchResult // buffered, so the fetch never waits for the loop
+}
+
+// startFetch fetches on a goroutine of its own, labelled role=fetch.
+func (s *sub) startFetch(ctx context.Context) *fetchCycle {
+	c := &fetchCycle{done: make(chan fetchResult, 1)}
+	c.ctx, c.task = trace.NewTask(ctx, "fetch cycle")
+	go pprof.Do(c.ctx, pprof.Labels("role", "fetch"), func(ctx context.Context) {
+		var r fetchResult
+		trace.WithRegion(ctx, "fetch", func() {
+			r.fetched, r.next, r.err = fetchContext(ctx, s.fetcher)
+		})
+		c.done <- r
+	})
+	return c
+}
+
+// wait waits for a cancelled fetch and drops its result.
+func (c *fetchCycle) wait() {
+	<-c.done
+	c.task.End()
+}
+
+// fetchTimer is the loop's one timer, armed only while a fetch may
+// start, instead of a new time.After on every iteration. With a
+// Scheduler, the wheel stands in for the timer.
+type fetchTimer struct {
+	s *sub
+	t Timer
+	c <-chan time.Time // nil while not armed
+}
+
+func (s *sub) newFetchTimer() *fetchTimer {
+	t := s.clock.NewTimer(0)
+	t.Stop()
+	return &fetchTimer{s: s, t: t}
+}
+
+// arm returns a channel that fires at next, arming the timer unless it
+// already is.
+func (f *fetchTimer) arm(next time.Time) <-chan time.Time {
+	if f.c == nil {
+		f.c = f.s.arm(f.t, next)
+	}
+	return f.c
+}
+
+// fired records that the channel from arm has fired.
+func (f *fetchTimer) fired() {
+	f.c = nil
+}
+
+func (f *fetchTimer) stop() {
+	if f.c != nil {
+		f.t.Stop() // a wakeup from the wheel is just ignored
+		f.c = nil
+	}
+}
+
+// pulse times the heartbeats of SubscribeHeartbeat. Without an
+// interval its channel is nil and never fires.
+type pulse struct {
+	c <-chan time.Time
+	t Timer
+	d time.Duration
+}
+
+func (s *sub) newPulse() *pulse {
+	if s.interval <= 0 {
+		return &pulse{}
+	}
+	t := s.clock.NewTimer(s.interval)
+	return &pulse{c: t.C(), t: t, d: s.interval}
+}
+
+func (p *pulse) reset() {
+	p.t.Reset(p.d)
+}
+
+func (p *pulse) stop() {
+	if p.t != nil {
+		p.t.Stop()
+	}
+}
+
+// labelLoop sets the pprof labels of the loop goroutine, and returns a
+// context carrying them for its fetches.
+func (s *sub) labelLoop() context.Context {
+	ctx := pprof.WithLabels(context.Background(), s.labels())
+	pprof.SetGoroutineLabels(ctx)
+	return ctx
+}
+
+// labels are the pprof labels of the loop goroutine, and of its fetch
+// goroutines with the role changed, so CPU and goroutine profiles of
+// many subscriptions can be told apart by feed. The feed is the name
+// the subscription was given, or else the fetcher's String.
+func (s *sub) labels() pprof.LabelSet {
+	feed := s.feed
+	if str, ok := s.fetcher.(fmt.Stringer); ok && feed == "" {
+		feed = str.String()
+	}
+	if feed == "" {
+		return pprof.Labels("role", "loop")
+	}
+	return pprof.Labels("feed", feed, "role", "loop")
+}
+
+// fetchContext fetches from f, cancelled by ctx if f supports it.
+func fetchContext(ctx context.Context, f Fetcher) ([]Item, time.Time, error) {
+	if cf, ok := f.(ContextFetcher); ok {
+		return cf.FetchContext(ctx)
+	}
+	return f.Fetch()
+}
+
+// arm returns a channel that fires at next.
+func (s *sub) arm(t Timer, next time.Time) <-chan time.Time {
+	if s.sched != nil {
+		return s.sched.At(next)
+	}
+	t.Reset(next.Sub(s.clock.Now()))
+	return t.C()
+}
+
+// finish records err for later Close calls and closes Updates.
+func (s *sub) finish(err error) {
+	s.err = err
+	close(s.updates)
+	close(s.heartbeat)
+	s.mailbox.Stop()
 }
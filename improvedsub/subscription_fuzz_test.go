@@ -0,0 +1,91 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+// FuzzSubscriptionLoop drives sub.loop with fetch results and consumer
+// actions read from the input, on a FakeClock, and checks what must
+// hold whatever the timing: no GUID is delivered twice, pending stays
+// bounded, and Close returns and closes Updates.
+//
+// Run it with go test -fuzz FuzzSubscriptionLoop $(ls *.go).
+func FuzzSubscriptionLoop(f *testing.F) {
+	f.Add([]byte{3, 1, 0, 0, 1, 2, 0, 0, 3, 9, 0, 1, 0, 2})
+	f.Add([]byte{7, 0, 4, 0, 0, 0, 7, 4, 1, 1, 1, 1, 2, 2})
+	f.Add([]byte{0, 5, 2, 1, 1, 0, 0, 0, 0, 0, 0, 0, 0, 0})
+	f.Add([]byte{15, 3, 8, 3, 15, 3, 0, 1, 0, 1, 0, 1, 2})
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		const maxPending = 10 // as in sub.loop
+
+		// The first half of the input is the script, three bytes a
+		// fetch; the rest are consumer actions.
+		script, actions := data[:len(data)/2], data[len(data)/2:]
+		var responses []Response
+		largest := 0
+		for i := 0; i+2 < len(script); i += 3 {
+			count, timing, flags := int(script[i]%16), script[i+1], script[i+2]
+			r := Response{
+				Next:    time.Duration(timing%8) * time.Second,
+				Latency: time.Duration(flags%4) * time.Second,
+			}
+			if flags&4 != 0 {
+				r.Err = errors.New("fetch failed")
+			}
+			for j := range count {
+				// A small pool of GUIDs, so fetches overlap
+				r.Items = append(r.Items, Item{GUID: fmt.Sprint((int(timing) + j) % 12)})
+			}
+			largest = max(largest, count)
+			responses = append(responses, r)
+		}
+
+		clock := NewFakeClock(time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC))
+		s := SubscribeClock(NewScriptedFetcher(clock, responses...), clock).(*sub)
+		delivered := make(map[string]bool)
+		receive := func(it Item) {
+			if delivered[it.GUID] {
+				t.Fatalf("%s delivered twice", it.GUID)
+			}
+			delivered[it.GUID] = true
+		}
+
+		for _, a := range actions {
+			switch a % 3 {
+			case 0:
+				select {
+				case it := <-s.Updates():
+					receive(it)
+				case <-time.After(time.Millisecond):
+				}
+			case 1:
+				clock.Advance(time.Duration(a/3%8) * time.Second)
+			case 2:
+				// A fetch only starts below maxPending, so one fetch
+				// can take it at most this far past
+				bound := max(maxPending, maxPending-1+largest)
+				if n := s.Pending().Count; n > bound {
+					t.Fatalf("%d items pending, want at most %d", n, bound)
+				}
+			}
+		}
+
+		closed := make(chan struct{})
+		go func() {
+			s.Close()
+			close(closed)
+		}()
+		select {
+		case <-closed:
+		case <-time.After(5 * time.Second):
+			t.Fatal("Close did not return")
+		}
+		for it := range s.Updates() { // must be closed, and may hold no more
+			t.Fatalf("%s delivered after Close returned", it.GUID)
+		}
+	})
+}
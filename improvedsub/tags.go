@@ -0,0 +1,55 @@
+package main
+
+import (
+	"context"
+	"slices"
+	"time"
+)
+
+// Tag returns a Fetcher that adds tags to every item fetched by f, so
+// feeds can be organized into groups ("work", "security") and items
+// routed by group further down the stream.
+func Tag(f Fetcher, tags ...string) Fetcher {
+	return &tagFetcher{fetcher: f, tags: tags}
+}
+
+type tagFetcher struct {
+	fetcher Fetcher
+	tags    []string
+}
+
+func (f *tagFetcher) Fetch() (items []Item, next time.Time, err error) {
+	return f.FetchContext(context.Background())
+}
+
+func (f *tagFetcher) FetchContext(ctx context.Context) (items []Item, next time.Time, err error) {
+	items, next, err = fetchContext(ctx, f.fetcher)
+	for i := range items {
+		items[i].Tags = withTags(items[i].Tags, f.tags)
+	}
+	return
+}
+
+// withTags returns have plus any of add it doesn't already contain.
+func withTags(have, add []string) []string {
+	tags := slices.Clip(have)
+	for _, t := range add {
+		if !slices.Contains(tags, t) {
+			tags = append(tags, t)
+		}
+	}
+	return tags
+}
+
+// HasTag reports whether it carries tag.
+func (it Item) HasTag(tag string) bool {
+	return slices.Contains(it.Tags, tag)
+}
+
+// AnyTag returns a selector matching items that carry at least one
+// of tags.
+func AnyTag(tags ...string) func(Item) bool {
+	return func(it Item) bool {
+		return slices.ContainsFunc(tags, it.HasTag)
+	}
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// ItemTemplate renders an Item as text with text/template, so the
+// payload a sink sends (a webhook body, a notification, an email
+// subject) can be changed in configuration:
+//
+//	{"text": {{json .Title}}, "url": {{json .GUID}}}
+//	[{{upper .Channel}}] {{trunc 60 .Title}}
+//
+// Besides the template builtins, it provides json (a JSON encoded
+// value), upper, lower, trunc (at most n runes), join (tags with a
+// separator) and date (Published in a time layout).
+type ItemTemplate struct {
+	t *template.Template
+}
+
+var templateFuncs = template.FuncMap{
+	"json": func(v any) (string, error) {
+		b, err := json.Marshal(v)
+		return string(b), err
+	},
+	"upper": strings.ToUpper,
+	"lower": strings.ToLower,
+	"trunc": func(n int, s string) string {
+		if r := []rune(s); len(r) > n {
+			return string(r[:n])
+		}
+		return s
+	},
+	"join": func(sep string, s []string) string { return strings.Join(s, sep) },
+	"date": func(layout string, t time.Time) string { return t.Format(layout) },
+}
+
+// CompileTemplate parses text as an ItemTemplate.
+func CompileTemplate(text string) (*ItemTemplate, error) {
+	t, err := template.New("item").Funcs(templateFuncs).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, err
+	}
+	return &ItemTemplate{t: t}, nil
+}
+
+// Render returns the template applied to it.
+func (t *ItemTemplate) Render(it Item) (string, error) {
+	var b strings.Builder
+	if err := t.t.Execute(&b, it); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+// TemplateSink returns a Sink that renders each item with t and hands
+// the result to deliver.
+func TemplateSink(t *ItemTemplate, deliver func(payload string) error) Sink {
+	return SinkFunc(func(it Item) error {
+		payload, err := t.Render(it)
+		if err != nil {
+			return err
+		}
+		return deliver(payload)
+	})
+}
+
+// TemplateWriterSink returns a Sink writing each item to w rendered
+// with t, one per line.
+func TemplateWriterSink(w io.Writer, t *ItemTemplate) Sink {
+	return TemplateSink(t, func(payload string) error {
+		_, err := io.WriteString(w, payload+"\n")
+		return err
+	})
+}
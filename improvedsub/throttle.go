@@ -0,0 +1,120 @@
+package main
+
+import "time"
+
+// Throttle wraps s and passes on at most n items per period, in bursts
+// of up to n, so the merged stream can feed an API with a rate limit;
+// per must be positive. Items arriving faster are buffered, up to n of
+// them, and policy says what happens once the buffer is full, as for a
+// Broadcast reader: Block stops reading from s until there is room
+// again, the others drop or conflate items.
+func Throttle(s Subscription, n int, per time.Duration, policy SlowReaderPolicy) Subscription {
+	n = max(n, 1)
+	t := &throttled{
+		sub:     s,
+		bucket:  NewTokenBucket(float64(n)/per.Seconds(), n),
+		buffer:  n,
+		policy:  policy,
+		updates: make(chan Item),
+		closing: make(chan chan error),
+		done:    make(chan struct{}),
+	}
+	go t.loop()
+	return t
+}
+
+type throttled struct {
+	sub     Subscription
+	bucket  *TokenBucket // only used by loop
+	buffer  int
+	policy  SlowReaderPolicy
+	updates chan Item
+	closing chan chan error
+	done    chan struct{} // closed when loop returns
+	err     error         // from closing sub, set before done
+}
+
+func (t *throttled) Updates() <-chan Item {
+	return t.updates
+}
+
+func (t *throttled) Close() error {
+	errc := make(chan error)
+	select {
+	case t.closing <- errc:
+		return <-errc
+	case <-t.done:
+		return t.err
+	}
+}
+
+func (t *throttled) loop() {
+	defer close(t.done)
+	stop := func() {
+		t.err = t.sub.Close()
+		close(t.updates)
+	}
+
+	// One timer, armed while items wait for a token
+	timer := time.NewTimer(0)
+	timer.Stop()
+	defer timer.Stop()
+	var armed bool
+
+	received := t.sub.Updates()
+	var pending []queued
+
+	for {
+		if received == nil && len(pending) == 0 {
+			stop() // s ended and everything from it was delivered
+			return
+		}
+
+		var in <-chan Item
+		if len(pending) < t.buffer || t.policy != Block {
+			in = received
+		}
+
+		var first Item
+		var updates chan Item
+		var refilled <-chan time.Time
+		if len(pending) > 0 {
+			if wait := t.bucket.Delay(); wait > 0 {
+				if !armed {
+					timer.Reset(wait)
+					armed = true
+				}
+				refilled = timer.C
+			} else {
+				first, updates = pending[0].it, t.updates
+			}
+		}
+
+		select {
+		case errc := <-t.closing:
+			stop()
+			errc <- t.err
+			return
+		case it, ok := <-in:
+			if !ok {
+				received = nil
+				break
+			}
+			if t.policy == Conflate && conflate(pending, it) {
+				break
+			}
+			if len(pending) == t.buffer {
+				if t.policy == DropNewest {
+					break
+				}
+				pending = pending[1:] // DropOldest or Conflate
+			}
+			pending = append(pending, queued{it, time.Now()})
+		case <-refilled:
+			armed = false
+		case updates <- first:
+			t.bucket.Allow() // there was a token, and only loop takes them
+			pending = pending[1:]
+		}
+	}
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"errors"
+	"net"
+	"net/url"
+	"regexp"
+)
+
+// HTTPUserAgent is sent by HTTP fetchers whose feed sets no User-Agent
+// of its own. Polite crawlers say how to reach whoever runs them, as
+// in "feedreader/1.0 (+https://example.com/bot; ops@example.com)".
+var HTTPUserAgent string
+
+// ErrNoContact is returned by FetchJSON and FetchRSS for a feed on a
+// remote host when its User-Agent gives no contact URL or email
+// address.
+var ErrNoContact = errors.New("user agent has no contact URL or email")
+
+var contactPattern = regexp.MustCompile(`https?://\S+|[^\s@;()]+@[^\s@;()]+\.[^\s@;()]+`)
+
+// checkUserAgent returns ErrNoContact unless ua has a contact or
+// rawURL is on this machine, where nobody needs to be told who is
+// polling.
+func checkUserAgent(ua, rawURL string) error {
+	if contactPattern.MatchString(ua) {
+		return nil
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return err
+	}
+	host := u.Hostname()
+	if ip := net.ParseIP(host); host == "localhost" || (ip != nil && ip.IsLoopback()) {
+		return nil
+	}
+	return ErrNoContact
+}
@@ -0,0 +1,116 @@
+package main
+
+import "time"
+
+// BatchSubscription delivers Items in chunks over a channel.
+// Close cancels it, closes the Updates channel and returns the error
+// of the subscription it wraps, if any.
+type BatchSubscription interface {
+	Updates() <-chan []Item
+	Close() error
+}
+
+// Window wraps s and passes its items on in chunks: a chunk is sent
+// once it holds size items, or maxDelay after its first item arrived,
+// whichever comes first, so a batch processor such as a bulk indexer
+// gets full batches under load and no item waits long when it is
+// quiet. While a chunk waits for the consumer, s is not read. A
+// partial chunk is sent when s ends.
+func Window(s Subscription, size int, maxDelay time.Duration) BatchSubscription {
+	w := &windowed{
+		sub:      s,
+		size:     max(size, 1),
+		maxDelay: maxDelay,
+		updates:  make(chan []Item),
+		closing:  make(chan chan error),
+		done:     make(chan struct{}),
+	}
+	go w.loop()
+	return w
+}
+
+type windowed struct {
+	sub      Subscription
+	size     int
+	maxDelay time.Duration
+	updates  chan []Item
+	closing  chan chan error
+	done     chan struct{} // closed when loop returns
+	err      error         // from closing sub, set before done
+}
+
+func (w *windowed) Updates() <-chan []Item {
+	return w.updates
+}
+
+func (w *windowed) Close() error {
+	errc := make(chan error)
+	select {
+	case w.closing <- errc:
+		return <-errc
+	case <-w.done:
+		return w.err
+	}
+}
+
+func (w *windowed) loop() {
+	defer close(w.done)
+	stop := func() {
+		w.err = w.sub.Close()
+		close(w.updates)
+	}
+
+	// One timer, armed by the first item of each chunk
+	timer := time.NewTimer(w.maxDelay)
+	timer.Stop()
+	defer timer.Stop()
+
+	received := w.sub.Updates()
+	var chunk []Item
+	var ready bool // chunk is full or due
+
+	for {
+		if received == nil && len(chunk) == 0 {
+			stop() // s ended and everything from it was delivered
+			return
+		}
+
+		var in <-chan Item
+		var due <-chan time.Time
+		var updates chan []Item
+		if ready {
+			updates = w.updates
+		} else {
+			in = received
+			if len(chunk) > 0 {
+				due = timer.C
+			}
+		}
+
+		select {
+		case errc := <-w.closing:
+			stop()
+			errc <- w.err
+			return
+		case it, ok := <-in:
+			if !ok {
+				received = nil
+				timer.Stop()
+				ready = len(chunk) > 0
+				break
+			}
+			if len(chunk) == 0 {
+				timer.Reset(w.maxDelay)
+			}
+			chunk = append(chunk, it)
+			if len(chunk) == w.size {
+				timer.Stop()
+				ready = true
+			}
+		case <-due:
+			ready = true
+		case updates <- chunk:
+			chunk, ready = nil, false
+		}
+	}
+}
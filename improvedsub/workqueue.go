@@ -0,0 +1,163 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// ErrUnknownLease is returned by Ack and Nack for a lease that does not
+// exist, most likely because it expired and the item went to someone
+// else.
+var ErrUnknownLease = errors.New("work queue: unknown or expired lease")
+
+// ErrWorkQueueClosed is returned by Send once the queue has been closed.
+var ErrWorkQueueClosed = errors.New("work queue: closed")
+
+// WorkQueue is a Sink that holds items until an external worker takes
+// them, so processing can happen in any language that speaks HTTP.
+// A worker leases an item, and acks it when done or nacks it to give
+// it back. An item whose lease runs out before either is handed out
+// again, so every item is processed at least once, maybe more. Send
+// blocks while the queue holds size items not yet acked, which pushes
+// back on whatever feeds the sink, until the queue is closed.
+type WorkQueue struct {
+	visibility time.Duration
+	slots      chan struct{} // one per item held
+	closed     chan struct{}
+	once       sync.Once
+
+	mu     sync.Mutex
+	ready  []Item
+	leased map[string]lease
+	nextID uint64
+}
+
+type lease struct {
+	it    Item
+	until time.Time
+}
+
+// Lease is what a worker gets: the item and the id to ack it with
+// before Until.
+type Lease struct {
+	ID    string    `json:"id"`
+	Item  Item      `json:"item"`
+	Until time.Time `json:"until"`
+}
+
+// NewWorkQueue returns a queue of up to size items, leased for
+// visibility at a time.
+func NewWorkQueue(size int, visibility time.Duration) *WorkQueue {
+	return &WorkQueue{
+		visibility: visibility,
+		slots:      make(chan struct{}, max(size, 1)),
+		closed:     make(chan struct{}),
+		leased:     make(map[string]lease),
+	}
+}
+
+func (q *WorkQueue) Send(it Item) error {
+	select {
+	case <-q.closed:
+		return ErrWorkQueueClosed
+	default:
+	}
+	select {
+	case q.slots <- struct{}{}:
+	case <-q.closed:
+		return ErrWorkQueueClosed
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.ready = append(q.ready, it)
+	return nil
+}
+
+// Close makes Send fail, waking any Send waiting for room, so whatever
+// feeds the queue can stop even if no worker ever acks again. Items
+// already held can still be leased, acked and nacked. It can be called
+// more than once.
+func (q *WorkQueue) Close() {
+	q.once.Do(func() { close(q.closed) })
+}
+
+// Lease hands out the oldest item available, if any.
+func (q *WorkQueue) Lease() (Lease, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	now := time.Now()
+	for id, l := range q.leased {
+		if now.After(l.until) {
+			delete(q.leased, id)
+			q.ready = append(q.ready, l.it)
+		}
+	}
+	if len(q.ready) == 0 {
+		return Lease{}, false
+	}
+	it := q.ready[0]
+	q.ready = q.ready[1:]
+	q.nextID++
+	l := Lease{ID: strconv.FormatUint(q.nextID, 10), Item: it, Until: now.Add(q.visibility)}
+	q.leased[l.ID] = lease{it, l.Until}
+	return l, true
+}
+
+// Ack removes a leased item for good.
+func (q *WorkQueue) Ack(id string) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if _, ok := q.leased[id]; !ok {
+		return ErrUnknownLease
+	}
+	delete(q.leased, id)
+	<-q.slots
+	return nil
+}
+
+// Nack gives a leased item back, behind the items already waiting.
+func (q *WorkQueue) Nack(id string) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	l, ok := q.leased[id]
+	if !ok {
+		return ErrUnknownLease
+	}
+	delete(q.leased, id)
+	q.ready = append(q.ready, l.it)
+	return nil
+}
+
+// Handler serves the queue to workers:
+//
+//	POST /lease      200 with a Lease as JSON, or 204 if there is no work
+//	POST /ack/{id}   204, or 404 for an unknown lease
+//	POST /nack/{id}  204, or 404 for an unknown lease
+func (q *WorkQueue) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /lease", func(w http.ResponseWriter, r *http.Request) {
+		l, ok := q.Lease()
+		if !ok {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(l)
+	})
+	settle := func(f func(string) error) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			if err := f(r.PathValue("id")); err != nil {
+				http.Error(w, err.Error(), http.StatusNotFound)
+				return
+			}
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}
+	mux.HandleFunc("POST /ack/{id}", settle(q.Ack))
+	mux.HandleFunc("POST /nack/{id}", settle(q.Nack))
+	return mux
+}
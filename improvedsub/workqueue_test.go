@@ -0,0 +1,54 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestWorkQueueCloseWakesBlockedSend(t *testing.T) {
+	q := NewWorkQueue(1, time.Minute)
+	if err := q.Send(Item{GUID: "a"}); err != nil {
+		t.Fatal(err)
+	}
+	errc := make(chan error, 1)
+	go func() { errc <- q.Send(Item{GUID: "b"}) }() // full: waits for an ack
+	time.Sleep(10 * time.Millisecond)
+	q.Close()
+
+	select {
+	case err := <-errc:
+		if !errors.Is(err, ErrWorkQueueClosed) {
+			t.Fatalf("Send = %v; want ErrWorkQueueClosed", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Send still blocked after Close")
+	}
+	if l, ok := q.Lease(); !ok || l.Item.GUID != "a" {
+		t.Fatalf("Lease after Close = %+v, %v; want item a", l, ok)
+	}
+}
+
+func TestRouterRunReturnsWhenWorkQueueClosed(t *testing.T) {
+	q := NewWorkQueue(1, time.Minute)
+	r, err := NewRouter(map[string]Sink{"work": q}, []RouteRule{{Sinks: []string{"work"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	in := make(chanSub)
+	done := make(chan error, 1)
+	go func() { done <- r.Run(in) }()
+	in <- Item{GUID: "a"}
+	in <- Item{GUID: "b"} // blocks Run in Send: nobody acks
+	q.Close()
+	close(in)
+
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Fatal("Run = nil; want the closed queue reported")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run did not return")
+	}
+}
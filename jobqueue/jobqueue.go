@@ -0,0 +1,220 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ErrClosed is returned by Submit once Shutdown has been called.
+var ErrClosed = errors.New("jobqueue: closed")
+
+// RetryPolicy says how often a failed job is tried again and how long
+// to wait in between: Backoff before the first retry, doubling after
+// each one up to MaxBackoff.
+type RetryPolicy struct {
+	Attempts   int // tries in all, including the first; at least 1
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+}
+
+func (p RetryPolicy) delay(attempt int) time.Duration {
+	d := p.Backoff
+	for range attempt - 1 {
+		d *= 2
+		if p.MaxBackoff > 0 && d >= p.MaxBackoff {
+			return p.MaxBackoff
+		}
+	}
+	return d
+}
+
+// Job is a value together with how it has fared so far.
+type Job[T any] struct {
+	Value    T
+	Attempts int   // tries made
+	Err      error // from the last try
+}
+
+// Stats counts what a Queue has been through.
+type Stats struct {
+	Queued    int // waiting for a worker now
+	Submitted int64
+	Succeeded int64
+	Retried   int64 // retries scheduled
+	Failed    int64 // jobs moved to the dead letters
+}
+
+// Queue runs jobs on a fixed number of workers, with at most size of
+// them waiting. A job whose work fails is retried by its RetryPolicy;
+// one that fails every try, or is still unfinished when Shutdown gives
+// up, ends up in the dead letters instead of being lost.
+type Queue[T any] struct {
+	work   func(context.Context, T) error
+	policy RetryPolicy
+	jobs   chan Job[T]
+	ctx    context.Context // for work, cancelled when Shutdown gives up
+	cancel context.CancelFunc
+	quit   chan struct{}  // stops the workers
+	active sync.WaitGroup // jobs submitted and not yet settled
+	idle   sync.WaitGroup // workers still running
+
+	mu     sync.Mutex // guards closed and dead
+	closed bool
+	dead   []Job[T]
+
+	submitted, succeeded, retried, failed atomic.Int64
+}
+
+// New starts a queue of workers goroutines running work.
+func New[T any](size, workers int, policy RetryPolicy, work func(context.Context, T) error) *Queue[T] {
+	ctx, cancel := context.WithCancel(context.Background())
+	q := &Queue[T]{
+		work:   work,
+		policy: policy,
+		jobs:   make(chan Job[T], max(size, 1)),
+		ctx:    ctx,
+		cancel: cancel,
+		quit:   make(chan struct{}),
+	}
+	q.policy.Attempts = max(q.policy.Attempts, 1)
+	for range max(workers, 1) {
+		q.idle.Add(1)
+		go q.worker()
+	}
+	return q
+}
+
+// Submit queues v, waiting for room until ctx is done.
+func (q *Queue[T]) Submit(ctx context.Context, v T) error {
+	q.mu.Lock()
+	if q.closed {
+		q.mu.Unlock()
+		return ErrClosed
+	}
+	q.active.Add(1) // before Shutdown can start waiting
+	q.mu.Unlock()
+
+	select {
+	case q.jobs <- Job[T]{Value: v}:
+		q.submitted.Add(1)
+		return nil
+	case <-ctx.Done():
+		q.active.Done()
+		return ctx.Err()
+	}
+}
+
+// Shutdown stops taking jobs and waits for those already submitted to
+// succeed or fail for good, retries included. If ctx is done first, the
+// work in progress is cancelled, whatever has not finished goes to the
+// dead letters, and ctx's error is returned.
+func (q *Queue[T]) Shutdown(ctx context.Context) error {
+	q.mu.Lock()
+	q.closed = true
+	q.mu.Unlock()
+
+	settled := make(chan struct{})
+	go func() {
+		q.active.Wait()
+		close(settled)
+	}()
+	var err error
+	select {
+	case <-settled:
+	case <-ctx.Done():
+		err = ctx.Err()
+		q.cancel() // the rest now settle quickly, as dead letters
+		<-settled
+	}
+	q.cancel()
+	close(q.quit)
+	q.idle.Wait()
+	return err
+}
+
+// DeadLetters returns the jobs that failed for good so far, and forgets
+// them.
+func (q *Queue[T]) DeadLetters() []Job[T] {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	dead := q.dead
+	q.dead = nil
+	return dead
+}
+
+// Stats returns the current queue length and the counters so far.
+func (q *Queue[T]) Stats() Stats {
+	return Stats{
+		Queued:    len(q.jobs),
+		Submitted: q.submitted.Load(),
+		Succeeded: q.succeeded.Load(),
+		Retried:   q.retried.Load(),
+		Failed:    q.failed.Load(),
+	}
+}
+
+func (q *Queue[T]) worker() {
+	defer q.idle.Done()
+	for {
+		select {
+		case j := <-q.jobs:
+			q.run(j)
+		case <-q.quit:
+			return
+		}
+	}
+}
+
+// run tries j once and settles it, or schedules its retry.
+func (q *Queue[T]) run(j Job[T]) {
+	if err := q.ctx.Err(); err != nil {
+		j.Err = err
+		q.bury(j)
+		return
+	}
+	j.Attempts++
+	j.Err = q.work(q.ctx, j.Value)
+	switch {
+	case j.Err == nil:
+		q.succeeded.Add(1)
+		q.active.Done()
+	case j.Attempts < q.policy.Attempts && q.ctx.Err() == nil:
+		q.retried.Add(1)
+		// Waiting, and then waiting for room, happen off the worker:
+		// workers blocked on a full queue of their own retries would
+		// never take anything from it again.
+		go q.retry(j, q.policy.delay(j.Attempts))
+	default:
+		q.bury(j)
+	}
+}
+
+func (q *Queue[T]) retry(j Job[T], after time.Duration) {
+	t := time.NewTimer(after)
+	defer t.Stop()
+	select {
+	case <-t.C:
+	case <-q.ctx.Done():
+		j.Err = q.ctx.Err()
+		q.bury(j)
+		return
+	}
+	select {
+	case q.jobs <- j:
+	case <-q.ctx.Done():
+		j.Err = q.ctx.Err()
+		q.bury(j)
+	}
+}
+
+// bury settles j as a dead letter.
+func (q *Queue[T]) bury(j Job[T]) {
+	q.mu.Lock()
+	q.dead = append(q.dead, j)
+	q.mu.Unlock()
+	q.failed.Add(1)
+	q.active.Done()
+}
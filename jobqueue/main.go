@@ -0,0 +1,42 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"time"
+)
+
+func main() {
+
+	// Deliver some notifications to a flaky endpoint
+	q := New(4, 2, RetryPolicy{Attempts: 3, Backoff: 50 * time.Millisecond, MaxBackoff: time.Second},
+		func(ctx context.Context, msg string) error {
+			select {
+			case <-time.After(time.Duration(rand.Intn(100)) * time.Millisecond):
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+			if rand.Intn(3) == 0 {
+				return errors.New("endpoint unavailable")
+			}
+			fmt.Println("delivered", msg)
+			return nil
+		})
+
+	for i := range 12 {
+		// Blocks while 4 jobs are waiting: backpressure on the producer
+		if err := q.Submit(context.Background(), fmt.Sprintf("message %d", i)); err != nil {
+			fmt.Println("Submit:", err)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	fmt.Println("Shutdown:", q.Shutdown(ctx))
+	fmt.Printf("%+v\n", q.Stats())
+	for _, j := range q.DeadLetters() {
+		fmt.Println("dead letter:", j.Value, "after", j.Attempts, "attempts:", j.Err)
+	}
+}
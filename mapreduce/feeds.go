@@ -0,0 +1,225 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+type Item struct {
+	Title, Channel, GUID string
+}
+
+type Fetcher interface {
+	// Fetches items for a given uri and returns the time when the next
+	// fetch should be attempted.
+	Fetch() (items []Item, next time.Time, err error)
+}
+
+// Subscription delivers Items over a channel, as in improvedsub.
+// Close cancels the subscription, closes the Updates channel and
+// returns the last fetch error, if any.
+type Subscription interface {
+	Updates() <-chan Item
+	Close() error
+}
+
+// Fetch returns a fake fetcher for domain, with an item every few
+// tens of milliseconds.
+func Fetch(domain string) Fetcher {
+	return &fakeFetcher{channel: domain}
+}
+
+type fakeFetcher struct {
+	channel string
+	n       int
+}
+
+func (f *fakeFetcher) Fetch() (items []Item, next time.Time, err error) {
+	it := Item{Channel: f.channel, Title: fmt.Sprintf("Item %d", f.n)}
+	it.GUID = it.Channel + "/" + it.Title
+	f.n++
+	return []Item{it}, time.Now().Add(time.Duration(rand.Intn(50)) * time.Millisecond), nil
+}
+
+// Subscribe returns a Subscription using fetcher to fetch Items. The
+// loop and Merge are trimmed copies of the ones in improvedsub: one
+// fetch timer, fetches off the loop goroutine, and at most maxPending
+// items held.
+func Subscribe(fetcher Fetcher) Subscription {
+	s := &sub{
+		fetcher: fetcher,
+		updates: make(chan Item),
+		closing: make(chan chan error),
+		done:    make(chan struct{}),
+	}
+	go s.loop()
+	return s
+}
+
+type sub struct {
+	fetcher Fetcher         // fetches Items
+	updates chan Item       // delivers Items to the user
+	closing chan chan error // for Close
+	done    chan struct{}   // closed when loop returns
+	err     error           // last fetch error, set before done
+}
+
+func (s *sub) Updates() <-chan Item {
+	return s.updates
+}
+
+func (s *sub) Close() error {
+	errc := make(chan error)
+	select {
+	case s.closing <- errc:
+		return <-errc
+	case <-s.done:
+		return s.err
+	}
+}
+
+func (s *sub) loop() {
+	defer close(s.done)
+	const maxPending = 10
+	type fetchResult struct {
+		fetched []Item
+		next    time.Time
+		err     error
+	}
+	var fetchDone chan fetchResult
+	var pending []Item
+	var next time.Time
+	var err error
+
+	// One timer for the whole loop, armed only while a fetch may start
+	fetchTimer := time.NewTimer(0)
+	fetchTimer.Stop()
+	defer fetchTimer.Stop()
+	var armed bool
+
+	for {
+		var startFetch <-chan time.Time
+		if fetchDone == nil && len(pending) < maxPending {
+			if !armed {
+				fetchTimer.Reset(time.Until(next))
+				armed = true
+			}
+			startFetch = fetchTimer.C
+		} else if armed {
+			fetchTimer.Stop()
+			armed = false
+		}
+
+		var first Item
+		var updates chan Item
+		if len(pending) > 0 {
+			first = pending[0]
+			updates = s.updates
+		}
+
+		select {
+		case errc := <-s.closing:
+			s.err = err
+			close(s.updates)
+			errc <- err
+			return
+		case <-startFetch:
+			armed = false
+			fetchDone = make(chan fetchResult, 1) // a late result is just dropped
+			go func() {
+				fetched, next, err := s.fetcher.Fetch()
+				fetchDone <- fetchResult{fetched, next, err}
+			}()
+		case result := <-fetchDone:
+			fetchDone = nil
+			next, err = result.next, result.err
+			if err != nil {
+				next = time.Now().Add(10 * time.Second)
+				break
+			}
+			pending = append(pending, result.fetched...)
+		case updates <- first:
+			pending = pending[1:]
+		}
+	}
+}
+
+type merge struct {
+	subs    []Subscription
+	updates chan Item
+	quit    chan struct{}
+	errs    chan error
+	once    sync.Once
+	err     error        // from the first Close
+	live    atomic.Int64 // subscriptions that have not ended
+	ended   sync.Once    // closes updates
+}
+
+// Merge fans the subscriptions into one. Closing it closes them all,
+// and its Updates is closed once they have all ended.
+func Merge(subs ...Subscription) Subscription {
+	m := &merge{
+		subs:    subs,
+		updates: make(chan Item),
+		quit:    make(chan struct{}),
+		errs:    make(chan error),
+	}
+	m.live.Store(int64(len(subs)))
+	if len(subs) == 0 {
+		m.end()
+	}
+	for _, s := range subs {
+		go func() {
+			for {
+				var it Item
+				var ok bool
+				select {
+				case it, ok = <-s.Updates():
+					if !ok {
+						m.end()
+						<-m.quit // Close still closes s and waits for us
+						m.errs <- s.Close()
+						return
+					}
+				case <-m.quit:
+					m.errs <- s.Close()
+					return
+				}
+				select {
+				case m.updates <- it:
+				case <-m.quit:
+					m.errs <- s.Close()
+					return
+				}
+			}
+		}()
+	}
+	return m
+}
+
+func (m *merge) Updates() <-chan Item {
+	return m.updates
+}
+
+func (m *merge) Close() error {
+	m.once.Do(func() {
+		close(m.quit)
+		for range m.subs {
+			if e := <-m.errs; e != nil {
+				m.err = e
+			}
+		}
+		m.ended.Do(func() { close(m.updates) })
+	})
+	return m.err
+}
+
+// end is called by each forwarder whose subscription ended on its own.
+func (m *merge) end() {
+	if m.live.Add(-1) <= 0 {
+		m.ended.Do(func() { close(m.updates) })
+	}
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+func main() {
+
+	// ctx bounds the aggregation even if the subscription is never closed
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	merged := Merge(
+		Subscribe(Fetch("blog.golang.org")),
+		Subscribe(Fetch("googleblog.blogspot.com")),
+		Subscribe(Fetch("googledevelopers.blogspot.com")))
+
+	// Closing the subscription closes Updates, which ends MapReduce
+	time.AfterFunc(3*time.Second, func() {
+		fmt.Println("Merged subscription closed. Errors:", merged.Close())
+	})
+
+	// Count items per channel, and in total
+	counts, err := MapReduce(ctx, merged.Updates(), 4,
+		func(it Item, emit func(string, int)) {
+			emit(it.Channel, 1)
+			emit("total", 1)
+		},
+		func(acc, n int) int { return acc + n })
+
+	for k, n := range counts {
+		fmt.Println(k, n)
+	}
+	fmt.Println("End of main, err:", err)
+}
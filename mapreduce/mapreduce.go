@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"sync"
+)
+
+// Pair is one key/value emitted by a mapper.
+type Pair[K comparable, V any] struct {
+	Key   K
+	Value V
+}
+
+// MapReduce runs mapper over every value from in, at most workers at a
+// time, and folds everything they emit into one map with reduce. The
+// mappers only ever send on a channel; the map belongs to the single
+// reducer goroutine, so neither needs a lock. It returns when in is
+// closed and everything is reduced, or early with ctx's error.
+func MapReduce[In any, K comparable, V any](
+	ctx context.Context,
+	in <-chan In,
+	workers int,
+	mapper func(v In, emit func(K, V)),
+	reduce func(acc, v V) V,
+) (map[K]V, error) {
+	pairs := make(chan Pair[K, V])
+	emit := func(k K, v V) {
+		select {
+		case pairs <- Pair[K, V]{k, v}:
+		case <-ctx.Done():
+		}
+	}
+
+	var wg sync.WaitGroup
+	for range max(workers, 1) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case v, ok := <-in:
+					if !ok {
+						return
+					}
+					mapper(v, emit)
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+	}
+	go func() {
+		wg.Wait() // only the last mapper out may close
+		close(pairs)
+	}()
+
+	result := make(map[K]V)
+	for p := range pairs {
+		result[p.Key] = reduce(result[p.Key], p.Value)
+	}
+	if err := ctx.Err(); err != nil {
+		return result, err
+	}
+	return result, nil
+}
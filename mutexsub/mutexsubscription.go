@@ -0,0 +1,101 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+func MutexSubscribe(fetcher Fetcher) Subscription {
+	s := &mutexSub{
+		fetcher: fetcher,
+		updates: make(chan Item),
+		quit:    make(chan struct{}),
+	}
+	s.cond = sync.NewCond(&s.mu)
+	go s.loop()
+	return s
+}
+
+// mutexSub is naiveSub with its three bugs fixed using a lock instead
+// of channels wherever a lock can do the job.
+type mutexSub struct {
+	fetcher Fetcher
+	updates chan Item
+	quit    chan struct{} // closed by Close, see loop
+
+	mu      sync.Mutex
+	cond    *sync.Cond // broadcast whenever closed or stopped changes
+	closed  bool       // set by Close
+	stopped bool       // set once loop has returned
+	err     error
+}
+
+func (s *mutexSub) Updates() <-chan Item {
+	return s.updates
+}
+
+// Close waits for loop to return, so err is final and updates is
+// closed by the time it does.
+func (s *mutexSub) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.closed {
+		s.closed = true
+		close(s.quit)
+		s.cond.Broadcast()
+	}
+	for !s.stopped {
+		s.cond.Wait()
+	}
+	return s.err
+}
+
+func (s *mutexSub) loop() {
+	defer func() {
+		s.mu.Lock()
+		s.stopped = true
+		close(s.updates)
+		s.cond.Broadcast()
+		s.mu.Unlock()
+	}()
+	for {
+		items, next, err := s.fetcher.Fetch()
+		if err != nil {
+			s.mu.Lock()
+			s.err = err // bug 1 fixed: written under the lock
+			s.mu.Unlock()
+			next = time.Now().Add(10 * time.Second)
+		}
+		for _, item := range items {
+			// bug 3: a lock can't interrupt a blocked send, so this is
+			// the one place where a channel is still needed.
+			select {
+			case s.updates <- item:
+			case <-s.quit:
+				return
+			}
+		}
+		if !s.sleepUntil(next) { // bug 2 fixed: Close wakes us up
+			return
+		}
+	}
+}
+
+// sleepUntil waits until t or until Close is called, and reports
+// whether it got to t. A timer broadcasts on cond when t comes, so
+// cond.Wait returns for either reason.
+func (s *mutexSub) sleepUntil(t time.Time) bool {
+	timer := time.AfterFunc(time.Until(t), func() {
+		s.mu.Lock()
+		s.cond.Broadcast()
+		s.mu.Unlock()
+	})
+	defer timer.Stop()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for !s.closed && time.Now().Before(t) {
+		s.cond.Wait()
+	}
+	return !s.closed
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// stepFetcher hands each fetch's result over a channel, so a test
+// decides when a fetch returns and what it returns. started receives
+// once each fetch has begun.
+type stepFetcher struct {
+	started chan struct{}
+	results chan fetchResult
+}
+
+type fetchResult struct {
+	items []Item
+	next  time.Time
+	err   error
+}
+
+func newStepFetcher() *stepFetcher {
+	return &stepFetcher{started: make(chan struct{}, 1), results: make(chan fetchResult)}
+}
+
+func (f *stepFetcher) Fetch() ([]Item, time.Time, error) {
+	f.started <- struct{}{}
+	r := <-f.results
+	return r.items, r.next, r.err
+}
+
+// closeWithin calls Close and fails t unless it returns within d.
+func closeWithin(t *testing.T, s Subscription, d time.Duration) error {
+	t.Helper()
+	errc := make(chan error, 1)
+	go func() { errc <- s.Close() }()
+	select {
+	case err := <-errc:
+		if _, ok := <-s.Updates(); ok {
+			t.Error("Updates still open after Close returned")
+		}
+		return err
+	case <-time.After(d):
+		t.Fatalf("Close did not return within %v", d)
+		return nil
+	}
+}
+
+func TestCloseDuringFetch(t *testing.T) {
+	f := newStepFetcher()
+	s := MutexSubscribe(f)
+	<-f.started
+
+	errc := make(chan error, 1)
+	go func() { errc <- s.Close() }()
+	select {
+	case <-errc:
+		t.Fatal("Close returned while a fetch was still running")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	// The fetch ends with an error and an item nobody will take
+	failed := errors.New("fetch failed")
+	f.results <- fetchResult{items: []Item{{GUID: "a"}}, err: failed}
+	select {
+	case err := <-errc:
+		if err != failed {
+			t.Errorf("Close returned %v, want %v", err, failed)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Close did not return after the fetch ended")
+	}
+}
+
+func TestCloseDuringSleep(t *testing.T) {
+	f := newStepFetcher()
+	s := MutexSubscribe(f)
+	<-f.started
+	f.results <- fetchResult{items: []Item{{GUID: "a"}}, next: time.Now().Add(time.Hour)}
+	if it := <-s.Updates(); it.GUID != "a" {
+		t.Fatalf("got %q, want a", it.GUID)
+	}
+	if err := closeWithin(t, s, time.Second); err != nil {
+		t.Errorf("Close: %v", err)
+	}
+}
+
+func TestCloseDuringSend(t *testing.T) {
+	f := newStepFetcher()
+	s := MutexSubscribe(f)
+	<-f.started
+	f.results <- fetchResult{items: []Item{{GUID: "a"}, {GUID: "b"}}}
+	time.Sleep(10 * time.Millisecond) // blocked sending a, with no reader
+	if err := closeWithin(t, s, time.Second); err != nil {
+		t.Errorf("Close: %v", err)
+	}
+}
+
+func TestConcurrentClose(t *testing.T) {
+	s := MutexSubscribe(Fetch("blog.golang.org"))
+	go func() {
+		for range s.Updates() {
+		}
+	}()
+	time.Sleep(10 * time.Millisecond)
+	errs := make(chan error)
+	for range 4 {
+		go func() { errs <- s.Close() }()
+	}
+	for range 4 {
+		select {
+		case <-errs:
+		case <-time.After(5 * time.Second):
+			t.Fatal("a concurrent Close did not return")
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"time"
+)
+
+type Item struct {
+	Title, Channel, GUID string // subset of RSS fields
+}
+
+type Fetcher interface {
+	// Fetches items for a given uri and returns the time when the next
+	// fetch should be attempted.
+	Fetch() (items []Item, next time.Time, err error)
+}
+
+// Subscription delivers Items over a channel.
+// Close cancels the subscription, closes the Updates channel and
+// returns the last fetch error, if any.
+type Subscription interface {
+	Updates() <-chan Item // stream of Items
+	Close() error         // close the stream
+}
+
+func main() {
+	seed := flag.Int64("seed", 0, "seed for the fake feeds' delays, random if 0")
+	flag.Parse()
+	if *seed == 0 {
+		*seed = time.Now().UnixNano()
+	}
+	FakeSeed = *seed
+	fmt.Println("Seed:", FakeSeed) // rerun with -seed to reproduce
+
+	// Subscribe to some feeds and create a merged update stream
+	merged := Merge(
+		MutexSubscribe(Fetch("blog.goland.org")),
+		MutexSubscribe(Fetch("googleblog.blogspot.com")),
+		MutexSubscribe(Fetch("googledevelopers.blogspot.com")))
+
+	// Close the subscription after some time
+	time.AfterFunc(3*time.Second, func() {
+		fmt.Println("Merged subsription closed. Errors: ", merged.Close())
+	})
+
+	// Print the stream.
+	// When updates channel is closed, range understands that and for loop ends.
+	for it := range merged.Updates() {
+		fmt.Println(it.Channel, it.Title)
+	}
+
+	time.Sleep(1 * time.Second)
+	fmt.Println("End of main")
+
+	panic("Show me the stacks")
+}
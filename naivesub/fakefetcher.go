@@ -2,6 +2,7 @@ package main
 
 import (
 	"fmt"
+	"hash/fnv"
 	"math/rand"
 	"time"
 )
@@ -11,19 +12,29 @@ func Fetch(domain string) Fetcher {
 }
 
 func fakeFetch(domain string) Fetcher {
-	return &fakeFetcher{channel: domain}
+	h := fnv.New64a()
+	h.Write([]byte(domain))
+	seed := FakeSeed ^ int64(h.Sum64())
+	return &fakeFetcher{channel: domain, rand: rand.New(rand.NewSource(seed))}
 }
 
 type fakeFetcher struct {
 	channel string
 	items   []Item
+	rand    *rand.Rand
 }
 
 var FakeDuplicates bool
 
+// FakeSeed seeds the fetch delays of fake fetchers created after it is
+// set. Each fetcher mixes in its domain and keeps its own source, so a
+// feed gets the same delays on every run with the same seed, however
+// the goroutines happen to be scheduled.
+var FakeSeed int64
+
 func (f *fakeFetcher) Fetch() (items []Item, next time.Time, err error) {
 	now := time.Now()
-	next = now.Add(time.Duration(rand.Intn(5)) * 500 * time.Millisecond)
+	next = now.Add(time.Duration(f.rand.Intn(5)) * 500 * time.Millisecond)
 	item := Item{
 		Channel: f.channel,
 		Title:   fmt.Sprintf("Item %d", len(f.items)),
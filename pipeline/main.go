@@ -0,0 +1,39 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+func main() {
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// Stages are plain values, so they can be built and combined
+	// before any goroutine is started.
+	clean := Compose(
+		Map(1, strings.TrimSpace),
+		Filter(func(s string) bool { return s != "" }),
+		Map(2, strings.ToUpper))
+	count := Then(clean, Map(1, func(s string) int { return len(s) }))
+
+	in := Source(ctx, " go ", "", "concurrency", "  ", "patterns")
+	total := 0
+	for n := range count(ctx, in) {
+		fmt.Println(n)
+		total += n
+	}
+	fmt.Println("Total: ", total)
+
+	// Cancelling stops every stage, even with values still in flight.
+	in = Source(ctx, "a", "b", "c", "d")
+	out := clean(ctx, in)
+	fmt.Println(<-out)
+	cancel()
+	for range out {
+	}
+
+	fmt.Println("End of main")
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"sync"
+)
+
+// Stage is one step of a pipeline. It reads from in until in is closed
+// or ctx is done, sends its output on the returned channel, and closes
+// that channel when it has finished, so the next stage's range ends.
+type Stage[In, Out any] func(ctx context.Context, in <-chan In) <-chan Out
+
+// Then feeds the output of s1 into s2.
+func Then[A, B, C any](s1 Stage[A, B], s2 Stage[B, C]) Stage[A, C] {
+	return func(ctx context.Context, in <-chan A) <-chan C {
+		return s2(ctx, s1(ctx, in))
+	}
+}
+
+// Compose chains stages of the same type, in order.
+func Compose[T any](stages ...Stage[T, T]) Stage[T, T] {
+	return func(ctx context.Context, in <-chan T) <-chan T {
+		for _, s := range stages {
+			in = s(ctx, in)
+		}
+		return in
+	}
+}
+
+// Map applies f to each value, using workers goroutines. With more
+// than one worker, values may come out in a different order.
+func Map[In, Out any](workers int, f func(In) Out) Stage[In, Out] {
+	return func(ctx context.Context, in <-chan In) <-chan Out {
+		out := make(chan Out)
+		var wg sync.WaitGroup
+		for range max(workers, 1) {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for v := range OrDone(ctx, in) {
+					select {
+					case out <- f(v):
+					case <-ctx.Done():
+						return
+					}
+				}
+			}()
+		}
+		go func() {
+			wg.Wait() // only the last worker out may close
+			close(out)
+		}()
+		return out
+	}
+}
+
+// Filter passes on only the values keep returns true for.
+func Filter[T any](keep func(T) bool) Stage[T, T] {
+	return func(ctx context.Context, in <-chan T) <-chan T {
+		out := make(chan T)
+		go func() {
+			defer close(out)
+			for v := range OrDone(ctx, in) {
+				if !keep(v) {
+					continue
+				}
+				select {
+				case out <- v:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+		return out
+	}
+}
+
+// Source returns a channel that yields values and is then closed.
+func Source[T any](ctx context.Context, values ...T) <-chan T {
+	out := make(chan T)
+	go func() {
+		defer close(out)
+		for _, v := range values {
+			select {
+			case out <- v:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out
+}
+
+// OrDone ranges over in until it is closed or ctx is done, whichever
+// comes first, so stages never block on a pipeline being torn down.
+func OrDone[T any](ctx context.Context, in <-chan T) <-chan T {
+	out := make(chan T)
+	go func() {
+		defer close(out)
+		for {
+			select {
+			case v, ok := <-in:
+				if !ok {
+					return
+				}
+				select {
+				case out <- v:
+				case <-ctx.Done():
+					return
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"runtime"
+	"slices"
+	"testing"
+	"time"
+)
+
+// noLeaks runs body and fails t if goroutines started by it are still
+// running shortly after it returns.
+func noLeaks(t *testing.T, body func()) {
+	t.Helper()
+	before := runtime.NumGoroutine()
+	body()
+	deadline := time.Now().Add(5 * time.Second)
+	for runtime.NumGoroutine() > before {
+		if time.Now().After(deadline) {
+			t.Fatalf("%d goroutines left behind", runtime.NumGoroutine()-before)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func appendStage(suffix string) Stage[string, string] {
+	return Map(1, func(s string) string { return s + suffix })
+}
+
+func TestComposeRunsStagesInOrder(t *testing.T) {
+	noLeaks(t, func() {
+		ctx := context.Background()
+		p := Compose(appendStage("a"), appendStage("b"), appendStage("c"))
+		var got []string
+		for s := range p(ctx, Source(ctx, "1", "2", "3")) {
+			got = append(got, s)
+		}
+		if want := []string{"1abc", "2abc", "3abc"}; !slices.Equal(got, want) {
+			t.Errorf("got %q, want %q", got, want)
+		}
+	})
+}
+
+func TestComposeOfNothingPassesValuesThrough(t *testing.T) {
+	ctx := context.Background()
+	var got []int
+	for v := range Compose[int]()(ctx, Source(ctx, 1, 2)) {
+		got = append(got, v)
+	}
+	if !slices.Equal(got, []int{1, 2}) {
+		t.Errorf("got %v, want [1 2]", got)
+	}
+}
+
+func TestThenChangesType(t *testing.T) {
+	ctx := context.Background()
+	p := Then(Filter(func(s string) bool { return s != "" }), Map(2, func(s string) int { return len(s) }))
+	total := 0
+	for n := range p(ctx, Source(ctx, "go", "", "chan")) {
+		total += n
+	}
+	if total != 6 {
+		t.Errorf("total %d, want 6", total)
+	}
+}
+
+func TestComposeClosesDownstreamWhenInputEnds(t *testing.T) {
+	noLeaks(t, func() {
+		ctx := context.Background()
+		in := make(chan string)
+		out := Compose(appendStage("a"), Filter(func(string) bool { return true }), appendStage("b"))(ctx, in)
+		in <- "x"
+		if got := <-out; got != "xab" {
+			t.Fatalf("got %q, want xab", got)
+		}
+		close(in)
+		select {
+		case _, ok := <-out:
+			if ok {
+				t.Fatal("value after the input was closed")
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("output still open after the input was closed")
+		}
+	})
+}
+
+func TestComposeStopsWhenCancelled(t *testing.T) {
+	noLeaks(t, func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		in := make(chan int) // never closed
+		out := Compose(Map(3, func(v int) int { return v * 2 }), Filter(func(int) bool { return true }))(ctx, in)
+		in <- 1
+		in <- 2 // values in flight, nobody reading out
+		cancel()
+
+		// Every stage must notice and close its output without being
+		// read from or having its input closed
+		select {
+		case <-drained(out):
+		case <-time.After(5 * time.Second):
+			t.Fatal("output still open after cancelling")
+		}
+	})
+}
+
+// drained returns a channel closed once c is closed, discarding values.
+func drained[T any](c <-chan T) <-chan struct{} {
+	done := make(chan struct{})
+	go func() {
+		for range c {
+		}
+		close(done)
+	}()
+	return done
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+var (
+	Web    = FakeSearch("web")
+	Web2   = FakeSearch("web")
+	Image  = FakeSearch("image")
+	Image2 = FakeSearch("image")
+	Video  = FakeSearch("video")
+	Video2 = FakeSearch("video")
+)
+
+func main() {
+
+	// Search 1.0: one backend after another
+	run("1.0", func(ctx context.Context, q string) ([]Result, error) {
+		var results []Result
+		for _, s := range []Search{Web, Image, Video} {
+			r, err := s(ctx, q)
+			if err != nil {
+				return results, err
+			}
+			results = append(results, r)
+		}
+		return results, nil
+	})
+
+	// Search 2.0: all at once, but don't wait for slow backends
+	run("2.0", func(ctx context.Context, q string) ([]Result, error) {
+		ctx, cancel := context.WithTimeout(ctx, 80*time.Millisecond)
+		defer cancel()
+		return FanOut(ctx, q, Web, Image, Video)
+	})
+
+	// Search 3.0: replicate each backend so timeouts become rare
+	run("3.0", func(ctx context.Context, q string) ([]Result, error) {
+		ctx, cancel := context.WithTimeout(ctx, 80*time.Millisecond)
+		defer cancel()
+		return FanOut(ctx, q, First(Web, Web2), First(Image, Image2), First(Video, Video2))
+	})
+}
+
+func run(version string, search func(context.Context, string) ([]Result, error)) {
+	start := time.Now()
+	results, err := search(context.Background(), "golang")
+	elapsed := time.Since(start)
+	fmt.Printf("Search %s: %v %v (err: %v)\n", version, results, elapsed, err)
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"time"
+)
+
+// Result is what one backend found for a query.
+type Result string
+
+// Search queries one backend. It should give up when ctx is done.
+type Search func(ctx context.Context, query string) (Result, error)
+
+// FakeSearch returns a backend of the given kind ("web", "image") that
+// takes up to 100ms to answer.
+func FakeSearch(kind string) Search {
+	return func(ctx context.Context, query string) (Result, error) {
+		select {
+		case <-time.After(time.Duration(rand.Intn(100)) * time.Millisecond):
+			return Result(fmt.Sprintf("%s result for %q", kind, query)), nil
+		case <-ctx.Done():
+			return "", ctx.Err()
+		}
+	}
+}
+
+// First queries all replicas at once and returns the first answer,
+// cancelling the others. Errors only count if every replica fails.
+func First(replicas ...Search) Search {
+	return func(ctx context.Context, query string) (Result, error) {
+		if len(replicas) == 0 {
+			return "", errors.New("search: no replicas")
+		}
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+
+		type reply struct {
+			r   Result
+			err error
+		}
+		replies := make(chan reply, len(replicas)) // losers must not block
+		for _, s := range replicas {
+			go func(s Search) {
+				r, err := s(ctx, query)
+				replies <- reply{r, err}
+			}(s)
+		}
+		var err error
+		for range replicas {
+			rep := <-replies
+			if rep.err == nil {
+				return rep.r, nil
+			}
+			err = rep.err
+		}
+		return "", err
+	}
+}
+
+// FanOut queries every backend concurrently and returns the results in
+// the order they arrive. It stops waiting when ctx is done, returning
+// what it has so far along with ctx's error; failed backends are left
+// out of the results and their errors joined into the one returned.
+func FanOut(ctx context.Context, query string, searches ...Search) ([]Result, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	type reply struct {
+		r   Result
+		err error
+	}
+	replies := make(chan reply, len(searches))
+	for _, s := range searches {
+		go func(s Search) {
+			r, err := s(ctx, query)
+			replies <- reply{r, err}
+		}(s)
+	}
+
+	var results []Result
+	var errs []error
+	for range searches {
+		select {
+		case rep := <-replies:
+			if rep.err != nil {
+				errs = append(errs, rep.err)
+				break
+			}
+			results = append(results, rep.r)
+		case <-ctx.Done():
+			return results, errors.Join(append(errs, ctx.Err())...)
+		}
+	}
+	return results, errors.Join(errs...)
+}
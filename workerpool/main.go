@@ -0,0 +1,43 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"math/rand"
+	"time"
+)
+
+func main() {
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	// Hash some words, pretending each one takes a while
+	pool := New(ctx, 4, true, func(ctx context.Context, word string) (string, error) {
+		select {
+		case <-time.After(time.Duration(rand.Intn(500)) * time.Millisecond):
+		case <-ctx.Done():
+			return "", ctx.Err()
+		}
+		return fmt.Sprintf("%x", sha256.Sum256([]byte(word)))[:12], nil
+	})
+
+	go func() {
+		defer pool.Close()
+		for _, w := range []string{"go", "concurrency", "patterns", "pools", "of", "workers", "fan", "out", "in"} {
+			if err := pool.Submit(w); err != nil {
+				fmt.Println("Submit: ", err)
+				return
+			}
+		}
+	}()
+
+	// Results arrive in submission order although the work is done
+	// concurrently; pass false to New to get them as they complete.
+	for r := range pool.Results() {
+		fmt.Println(r.Seq, r.Value, r.Err)
+	}
+
+	fmt.Println("End of main")
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// ErrClosed is returned by Submit once the pool has been closed.
+var ErrClosed = errors.New("workerpool: closed")
+
+// Result is the outcome of one job.
+type Result[R any] struct {
+	Value R
+	Err   error
+	Seq   int // position of the job in submission order, from 0
+}
+
+// Pool fans jobs of type T out to a fixed number of worker goroutines,
+// each running work, and delivers their results on a single channel.
+type Pool[T, R any] struct {
+	ctx     context.Context
+	work    func(context.Context, T) (R, error)
+	submit  chan T         // from Submit to dispatch
+	quit    chan struct{}  // closed by Close
+	once    sync.Once      // closes quit
+	jobs    chan job[T]    // from dispatch to the workers
+	done    chan Result[R] // from the workers to collect
+	results chan Result[R] // delivers Results to the user
+}
+
+type job[T any] struct {
+	seq int
+	v   T
+}
+
+// New starts a pool of workers goroutines. If ordered is set, results
+// are delivered in submission order, holding back fast results until
+// the slower ones submitted before them are done; otherwise they are
+// delivered as soon as they are ready. Cancelling ctx stops the pool:
+// pending jobs are abandoned and Results is closed once the workers
+// have returned.
+func New[T, R any](ctx context.Context, workers int, ordered bool, work func(context.Context, T) (R, error)) *Pool[T, R] {
+	p := &Pool[T, R]{
+		ctx:     ctx,
+		work:    work,
+		submit:  make(chan T),
+		quit:    make(chan struct{}),
+		jobs:    make(chan job[T]),
+		done:    make(chan Result[R]),
+		results: make(chan Result[R]),
+	}
+
+	go p.dispatch()
+	var wg sync.WaitGroup
+	for range max(workers, 1) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			p.worker()
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(p.done)
+	}()
+
+	if ordered {
+		go p.collectOrdered()
+	} else {
+		go p.collect()
+	}
+	return p
+}
+
+// Submit queues v, blocking until the pool is ready to take it, the
+// pool is closed or its context is cancelled.
+func (p *Pool[T, R]) Submit(v T) error {
+	select {
+	case <-p.quit:
+		return ErrClosed
+	default:
+	}
+	select {
+	case p.submit <- v:
+		return nil
+	case <-p.quit:
+		return ErrClosed
+	case <-p.ctx.Done():
+		return p.ctx.Err()
+	}
+}
+
+// Close tells the pool no more jobs are coming. It does not wait for a
+// blocked Submit, which returns ErrClosed. Results is closed once the
+// jobs already submitted have been delivered.
+func (p *Pool[T, R]) Close() {
+	p.once.Do(func() { close(p.quit) })
+}
+
+// Results returns the stream of results. It must be read until closed,
+// or the pool's context cancelled, for the workers to make progress.
+func (p *Pool[T, R]) Results() <-chan Result[R] {
+	return p.results
+}
+
+// dispatch numbers submitted jobs and hands them to the workers one at
+// a time, so Submit never holds anything while it waits.
+func (p *Pool[T, R]) dispatch() {
+	defer close(p.jobs)
+	for seq := 0; ; seq++ {
+		var v T
+		select {
+		case v = <-p.submit:
+		case <-p.quit:
+			return
+		case <-p.ctx.Done():
+			return
+		}
+		select {
+		case p.jobs <- job[T]{seq, v}:
+		case <-p.ctx.Done():
+			return
+		}
+	}
+}
+
+func (p *Pool[T, R]) worker() {
+	for {
+		var j job[T]
+		var ok bool
+		select {
+		case j, ok = <-p.jobs:
+			if !ok {
+				return
+			}
+		case <-p.ctx.Done():
+			return
+		}
+
+		v, err := p.work(p.ctx, j.v)
+		select {
+		case p.done <- Result[R]{Value: v, Err: err, Seq: j.seq}:
+		case <-p.ctx.Done():
+			return
+		}
+	}
+}
+
+func (p *Pool[T, R]) collect() {
+	defer close(p.results)
+	for r := range p.done {
+		select {
+		case p.results <- r:
+		case <-p.ctx.Done():
+			return
+		}
+	}
+}
+
+// collectOrdered keeps results that arrive early in waiting until
+// every result before them has been delivered.
+func (p *Pool[T, R]) collectOrdered() {
+	defer close(p.results)
+	waiting := make(map[int]Result[R])
+	next := 0
+	for r := range p.done {
+		waiting[r.Seq] = r
+		for {
+			r, ok := waiting[next]
+			if !ok {
+				break
+			}
+			select {
+			case p.results <- r:
+			case <-p.ctx.Done():
+				return
+			}
+			delete(waiting, next)
+			next++
+		}
+	}
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"runtime"
+	"slices"
+	"testing"
+	"time"
+)
+
+// noLeaks runs body and fails t if goroutines started by it are still
+// running shortly after it returns.
+func noLeaks(t *testing.T, body func()) {
+	t.Helper()
+	before := runtime.NumGoroutine()
+	body()
+	deadline := time.Now().Add(5 * time.Second)
+	for runtime.NumGoroutine() > before {
+		if time.Now().After(deadline) {
+			t.Fatalf("%d goroutines left behind", runtime.NumGoroutine()-before)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// square takes longer for smaller numbers, so results complete out of
+// submission order.
+func square(ctx context.Context, n int) (int, error) {
+	select {
+	case <-time.After(time.Duration(10-n) * time.Millisecond):
+	case <-ctx.Done():
+		return 0, ctx.Err()
+	}
+	return n * n, nil
+}
+
+func submitAll(t *testing.T, p *Pool[int, int], n int) {
+	go func() {
+		defer p.Close()
+		for i := range n {
+			if err := p.Submit(i); err != nil {
+				t.Errorf("Submit(%d): %v", i, err)
+				return
+			}
+		}
+	}()
+}
+
+func TestOrderedPoolDeliversInSubmissionOrder(t *testing.T) {
+	noLeaks(t, func() {
+		p := New(context.Background(), 4, true, square)
+		submitAll(t, p, 10)
+		var got []int
+		for r := range p.Results() {
+			if r.Seq != len(got) {
+				t.Errorf("result %d has Seq %d", len(got), r.Seq)
+			}
+			got = append(got, r.Value)
+		}
+		if want := []int{0, 1, 4, 9, 16, 25, 36, 49, 64, 81}; !slices.Equal(got, want) {
+			t.Errorf("got %v, want %v", got, want)
+		}
+	})
+}
+
+func TestUnorderedPoolDeliversEveryResult(t *testing.T) {
+	noLeaks(t, func() {
+		p := New(context.Background(), 4, false, square)
+		submitAll(t, p, 10)
+		var seqs []int
+		for r := range p.Results() {
+			if r.Value != r.Seq*r.Seq {
+				t.Errorf("Seq %d has value %d", r.Seq, r.Value)
+			}
+			seqs = append(seqs, r.Seq)
+		}
+		slices.Sort(seqs)
+		if want := []int{0, 1, 2, 3, 4, 5, 6, 7, 8, 9}; !slices.Equal(seqs, want) {
+			t.Errorf("got Seqs %v, want %v", seqs, want)
+		}
+	})
+}
+
+func TestCloseDoesNotWaitForBlockedSubmit(t *testing.T) {
+	noLeaks(t, func() {
+		release := make(chan struct{})
+		p := New(context.Background(), 1, false, func(ctx context.Context, n int) (int, error) {
+			<-release
+			return n, nil
+		})
+
+		// The worker takes the first job and dispatch holds the second,
+		// so the third Submit blocks
+		for i := range 2 {
+			if err := p.Submit(i); err != nil {
+				t.Fatalf("Submit(%d): %v", i, err)
+			}
+		}
+		blocked := make(chan error)
+		go func() { blocked <- p.Submit(2) }()
+		time.Sleep(10 * time.Millisecond)
+
+		closed := make(chan struct{})
+		go func() {
+			p.Close()
+			close(closed)
+		}()
+		select {
+		case <-closed:
+		case <-time.After(5 * time.Second):
+			t.Fatal("Close waited for a blocked Submit")
+		}
+		if err := <-blocked; !errors.Is(err, ErrClosed) {
+			t.Errorf("blocked Submit: got %v, want ErrClosed", err)
+		}
+		if err := p.Submit(3); !errors.Is(err, ErrClosed) {
+			t.Errorf("Submit after Close: got %v, want ErrClosed", err)
+		}
+		p.Close() // a second Close is harmless
+
+		// The jobs taken before Close are still delivered
+		close(release)
+		var got []int
+		for r := range p.Results() {
+			got = append(got, r.Value)
+		}
+		slices.Sort(got)
+		if !slices.Equal(got, []int{0, 1}) {
+			t.Errorf("got %v, want [0 1]", got)
+		}
+	})
+}
+
+func TestCancelStopsPool(t *testing.T) {
+	noLeaks(t, func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		p := New(ctx, 2, true, func(ctx context.Context, n int) (int, error) {
+			<-ctx.Done()
+			return 0, ctx.Err()
+		})
+		for i := range 3 {
+			if err := p.Submit(i); err != nil {
+				t.Fatalf("Submit(%d): %v", i, err)
+			}
+		}
+		cancel()
+		if err := p.Submit(3); !errors.Is(err, context.Canceled) {
+			t.Errorf("Submit after cancel: got %v, want context.Canceled", err)
+		}
+
+		// Results is closed without being read or Close being called
+		select {
+		case <-drained(p.Results()):
+		case <-time.After(5 * time.Second):
+			t.Fatal("Results still open after cancelling")
+		}
+	})
+}
+
+// drained returns a channel closed once c is closed, discarding values.
+func drained[T any](c <-chan T) <-chan struct{} {
+	done := make(chan struct{})
+	go func() {
+		for range c {
+		}
+		close(done)
+	}()
+	return done
+}